@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/username/otel-playground/internal/lib/certs"
+)
+
+func main() {
+	var (
+		out      string
+		services string
+		validity time.Duration
+	)
+	flag.StringVar(&out, "out", "deploys/certs", "The directory to write the certificates to")
+	flag.StringVar(
+		&services, "services", "digit,lower,upper,special,generator,load,collector", "Comma separated services names",
+	)
+	flag.DurationVar(&validity, "validity", 365*24*time.Hour, "How long the certificates are valid")
+	flag.Parse()
+
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		log.Fatalf("failed to create output directory: %v\n", err)
+	}
+
+	ca, err := certs.NewAuthority(out, validity)
+	if err != nil {
+		log.Fatalf("failed to create ca: %v\n", err)
+	}
+
+	for _, service := range strings.Split(services, ",") {
+		hosts := []string{service, "localhost", "127.0.0.1"}
+		if err := ca.Issue(out, service, hosts, validity); err != nil {
+			log.Fatalf("failed to issue certificate: %v\n", err)
+		}
+		log.Printf("issued certificate for '%s'\n", service)
+	}
+}
@@ -11,10 +11,12 @@ import (
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/baggage"
 	"go.opentelemetry.io/otel/trace"
 
+	"github.com/username/otel-playground/internal/lib/certs"
+	"github.com/username/otel-playground/internal/lib/chars"
 	"github.com/username/otel-playground/internal/lib/collections"
+	"github.com/username/otel-playground/internal/lib/environment"
 	"github.com/username/otel-playground/internal/lib/random"
 	"github.com/username/otel-playground/internal/lib/telemetry"
 	"github.com/username/otel-playground/internal/lib/web"
@@ -40,37 +42,74 @@ const (
 
 var tracer trace.Tracer
 
+var charsServed *chars.Counter
+
 func init() {
 	rand.Seed(time.Now().Unix())
 }
 
 func main() {
-	var port int
+	var port, adminPort int
 	flag.IntVar(&port, "port", 5000, "The port to listen on")
+	flag.IntVar(&adminPort, "admin-port", 0, "The port to serve pprof and runtime introspection on, 0 disables it")
 	flag.Parse()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	tlsConfig := certs.FromEnv()
+	serverTLS, err := tlsConfig.ServerTLS()
+	if err != nil {
+		log.Fatalf("failed to load server certificates: %v\n", err)
+	}
+	clientTLS, err := tlsConfig.ClientTLS()
+	if err != nil {
+		log.Fatalf("failed to load client certificates: %v\n", err)
+	}
+
+	baggagePolicy := web.BaggagePolicyFromEnv()
+	redactor := chars.Redactor()
+
 	client, err := telemetry.Configure(
 		ctx,
 		telemetry.WithServiceName(serviceName),
 		telemetry.WithServiceVersion(serviceVersion),
+		telemetry.WithExporterTLS(clientTLS),
+		telemetry.WithProfiling(telemetry.ProfilingConfigFromEnv()),
+		telemetry.WithTailSampling(telemetry.TailSamplingConfigFromEnv()),
+		telemetry.WithExporter(telemetry.SignalTraces, telemetry.ExportersFromEnv(telemetry.SignalTraces)...),
+		telemetry.WithExporter(telemetry.SignalMetrics, telemetry.ExportersFromEnv(telemetry.SignalMetrics)...),
+		telemetry.WithPrometheusEndpoint(environment.Get("PROMETHEUS_ENDPOINT", "")),
+		telemetry.WithStrict(environment.Get("TELEMETRY_STRICT", false)),
+		telemetry.WithSpanBaggage(baggagePolicy.Promote...),
+		telemetry.WithRedactor(redactor),
 	)
 	if err != nil {
 		log.Fatalf("failed to register tracer: %v\n", err)
 	}
-	defer func() {
-		client.Shutdown(context.Background())
-	}()
 
 	tracer = otel.Tracer("main")
 
-	mux := http.NewServeMux()
-	web.Handler(mux, "/", http.HandlerFunc(digitHandler))
-	web.HealthCheckHandler(mux, serviceName, serviceVersion)
+	if charsServed, err = chars.NewCounter(otel.Meter("main"), redactor); err != nil {
+		log.Fatalf("failed to create metrics: %v\n", err)
+	}
 
-	if err := web.Server(port, mux, serviceName, web.FilterURLs{"/healthcheck"}); err != nil {
+	router := web.NewRouter()
+	router.Get("/", digitHandler)
+	web.HealthCheckHandler(router, serviceName, serviceVersion)
+
+	srv := web.NewServer(
+		router,
+		web.WithPort(port),
+		web.WithAdminPort(adminPort),
+		web.WithName(serviceName),
+		web.WithFilters(web.FilterURLs{"/healthcheck"}),
+		web.WithTLS(serverTLS),
+		web.WithTelemetry(client),
+		web.WithBaggagePolicy(baggagePolicy),
+		web.WithResponseLogging(environment.Get("LOG_RESPONSES", false), redactor),
+	)
+	if err := srv.Run(); err != nil {
 		log.Fatalf("failed to start server: %v\n", err)
 	}
 }
@@ -78,20 +117,15 @@ func main() {
 func digitHandler(w http.ResponseWriter, r *http.Request) {
 	char := randomDigit(r.Context())
 	char = processDigit(r.Context(), char)
+	charsServed.Add(r.Context(), char)
 	web.WriteJSON(w, http.StatusOK, renderDigit(r.Context(), char))
 }
 
 func randomDigit(ctx context.Context) rune {
-	bag := baggage.FromContext(ctx)
-	_, span := tracer.Start(
-		ctx,
-		"random_digit",
-		trace.WithSpanKind(trace.SpanKindInternal),
-		trace.WithAttributes(attribute.String("baggage.username", bag.Member("username").Value())),
-	)
+	ctx, span := tracer.Start(ctx, "random_digit", trace.WithSpanKind(trace.SpanKindInternal))
 	defer span.End()
 
-	work(0.0003, 0.0001)
+	work(ctx, 0.0003, 0.0001)
 
 	// slowness varies with the minute of the hour
 	time.Sleep(time.Duration(math.Sin(float64(time.Now().Minute())) + 1.0))
@@ -113,20 +147,20 @@ func processDigit(ctx context.Context, char rune) rune {
 	ctx, span := tracer.Start(ctx, "process_digit", opts...)
 	defer span.End()
 
-	work(0.0001, 0.00005)
+	work(ctx, 0.0001, 0.00005)
 
 	// 1/100 calls is extra slow when the digit is even
 	// if random.random() > 0.99 and int(c) % 2 == 0:
 	if rand.Float64() > 0.99 && char%2 == 0 {
 		span.AddEvent("extra_work", trace.WithAttributes(attr))
 
-		work(0.0002, 0.0001)
+		work(ctx, 0.0002, 0.0001)
 	}
 
 	// these chars are extra slow
 	if collections.SliceContains(char, []rune{'4', '5', '6'}) {
-		if _, span := tracer.Start(ctx, "extra_process_digit", opts...); span != nil {
-			work(0.005, 0.0005)
+		if ctx, span := tracer.Start(ctx, "extra_process_digit", opts...); span != nil {
+			work(ctx, 0.005, 0.0005)
 			span.End()
 		}
 	}
@@ -140,19 +174,23 @@ func renderDigit(ctx context.Context, char rune) web.Envelope {
 		trace.WithSpanKind(trace.SpanKindInternal),
 	}
 
-	_, span := tracer.Start(ctx, "render_digit", opts...)
+	ctx, span := tracer.Start(ctx, "render_digit", opts...)
 	defer span.End()
 
-	work(0.0002, 0.0001)
+	work(ctx, 0.0002, 0.0001)
 
 	// every five minutes something goes wrong
 	if time.Now().Minute()%5 == 0 {
-		work(0.05, 0.005)
+		work(ctx, 0.05, 0.005)
 	}
 
 	return web.Envelope{"char": string(char)}
 }
 
-func work(mean, sigma float64) {
-	time.Sleep(time.Duration(random.Normalvariate(mean, sigma)))
+func work(ctx context.Context, mean, sigma float64) {
+	telemetry.Do(
+		ctx, func(context.Context) {
+			time.Sleep(time.Duration(random.Normalvariate(mean, sigma)))
+		},
+	)
 }
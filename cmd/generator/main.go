@@ -7,16 +7,18 @@ import (
 	"log"
 	"math/rand"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/baggage"
 	"go.opentelemetry.io/otel/trace"
 
+	"github.com/username/otel-playground/internal/lib/certs"
 	"github.com/username/otel-playground/internal/lib/environment"
 	"github.com/username/otel-playground/internal/lib/random"
+	"github.com/username/otel-playground/internal/lib/redact"
 	"github.com/username/otel-playground/internal/lib/telemetry"
 	"github.com/username/otel-playground/internal/lib/web"
 )
@@ -26,8 +28,20 @@ const (
 	serviceVersion = "1.0.0"
 )
 
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 64
+	maxAttempts       = 3
+)
+
 var tracer trace.Tracer
 
+type charResponse struct {
+	Char string `json:"char"`
+}
+
+var httpClient *web.Client
+
 type generator struct {
 	name, url string
 }
@@ -44,56 +58,116 @@ func init() {
 }
 
 func main() {
-	var port int
+	var port, adminPort int
 	flag.IntVar(&port, "port", 5000, "The port to listen on")
+	flag.IntVar(&adminPort, "admin-port", 0, "The port to serve pprof and runtime introspection on, 0 disables it")
 	flag.Parse()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	tlsConfig := certs.FromEnv()
+	serverTLS, err := tlsConfig.ServerTLS()
+	if err != nil {
+		log.Fatalf("failed to load server certificates: %v\n", err)
+	}
+	clientTLS, err := tlsConfig.ClientTLS()
+	if err != nil {
+		log.Fatalf("failed to load client certificates: %v\n", err)
+	}
+
+	baggagePolicy := web.BaggagePolicyFromEnv()
+	// the generated password must never be exported, even when set by mistake
+	redactor := redact.FromEnv(
+		redact.Rule{Pattern: "password", Action: redact.Drop},
+		redact.Rule{Pattern: "char", Action: redact.Hash},
+	)
+
 	client, err := telemetry.Configure(
 		ctx,
 		telemetry.WithServiceName(serviceName),
 		telemetry.WithServiceVersion(serviceVersion),
+		telemetry.WithExporterTLS(clientTLS),
+		telemetry.WithProfiling(telemetry.ProfilingConfigFromEnv()),
+		telemetry.WithTailSampling(telemetry.TailSamplingConfigFromEnv()),
+		telemetry.WithExporter(telemetry.SignalTraces, telemetry.ExportersFromEnv(telemetry.SignalTraces)...),
+		telemetry.WithExporter(telemetry.SignalMetrics, telemetry.ExportersFromEnv(telemetry.SignalMetrics)...),
+		telemetry.WithPrometheusEndpoint(environment.Get("PROMETHEUS_ENDPOINT", "")),
+		telemetry.WithStrict(environment.Get("TELEMETRY_STRICT", false)),
+		telemetry.WithSpanBaggage(baggagePolicy.Promote...),
+		telemetry.WithRedactor(redactor),
+		telemetry.WithViews(passwordViews...),
 	)
 	if err != nil {
 		log.Fatalf("failed to register tracer: %v\n", err)
 	}
-	defer func() {
-		client.Shutdown(context.Background())
-	}()
+
+	if metrics, err = newPasswordMetrics(); err != nil {
+		log.Fatalf("failed to create metrics: %v\n", err)
+	}
 
 	tracer = otel.Tracer("main")
+	httpClient = web.NewClient(web.WithTimeout(5*time.Second), web.WithTLSConfig(clientTLS))
 
-	mux := http.NewServeMux()
-	web.Handler(mux, "/", http.HandlerFunc(generatorHandler))
-	web.HealthCheckHandler(mux, serviceName, serviceVersion)
+	router := web.NewRouter()
+	router.Get("/", generatorHandler)
+	router.Get("/passwords/{length}", passwordHandler)
+	web.HealthCheckHandler(router, serviceName, serviceVersion)
 
-	if err := web.Server(port, mux, serviceName, web.FilterURLs{"/healthcheck"}); err != nil {
+	srv := web.NewServer(
+		router,
+		web.WithPort(port),
+		web.WithAdminPort(adminPort),
+		web.WithName(serviceName),
+		web.WithFilters(web.FilterURLs{"/healthcheck"}),
+		web.WithTLS(serverTLS),
+		web.WithTelemetry(client),
+		web.WithBaggagePolicy(baggagePolicy),
+		web.WithResponseLogging(environment.Get("LOG_RESPONSES", false), redactor),
+	)
+	if err := srv.Run(); err != nil {
 		log.Fatalf("failed to start server: %v\n", err)
 	}
 }
 
 func generatorHandler(w http.ResponseWriter, r *http.Request) {
-	bag, _ := baggage.Parse("username=donuts")
-	ctx := baggage.ContextWithBaggage(r.Context(), bag)
-	password, err := generate(ctx)
+	writePassword(w, r, 0)
+}
+
+func passwordHandler(w http.ResponseWriter, r *http.Request) {
+	length, err := strconv.Atoi(web.PathParam(r, "length"))
+	if err != nil || length < minPasswordLength || length > maxPasswordLength {
+		web.BadRequestResponse(
+			w, r, fmt.Errorf("length must be a number between %d and %d", minPasswordLength, maxPasswordLength),
+		)
+		return
+	}
+
+	writePassword(w, r, length)
+}
+
+// writePassword generates a password with the given length, or a random length when it is zero.
+func writePassword(w http.ResponseWriter, r *http.Request, length int) {
+	password, err := generate(r.Context(), length)
 	if err != nil {
-		web.ServerErrorResponse(w, err)
+		web.ErrorResponse(w, r, web.NewUpstreamError("failed to generate the password", err))
 		return
 	}
 
 	web.WriteJSON(w, http.StatusOK, web.Envelope{"password": password})
 }
 
-func generate(ctx context.Context) (string, error) {
+func generate(ctx context.Context, passwordLength int) (string, error) {
 	spctx, span := tracer.Start(ctx, "generator.generate", trace.WithSpanKind(trace.SpanKindInternal))
 	defer span.End()
 
 	var password []string
-	span.AddEvent("selecting_password_length")
-	work(0.00001, 0.00001)
-	passwordLength := random.NumberInRange(8, 25)
+	stats := passwordStats{randomLength: passwordLength == 0}
+	if passwordLength == 0 {
+		span.AddEvent("selecting_password_length")
+		work(spctx, 0.00001, 0.00001)
+		passwordLength = random.NumberInRange(8, 25)
+	}
 	span.SetAttributes(attribute.Int("password.length", passwordLength))
 
 	i := 1
@@ -102,7 +176,7 @@ func generate(ctx context.Context) (string, error) {
 		span.AddEvent(fmt.Sprintf("generate_loop_%d", i), trace.WithAttributes(attribute.Int("iteration", i)))
 
 		for _, gen := range generators {
-			chars, err := getChars(spctx, gen.name, gen.url)
+			chars, err := getChars(spctx, gen.name, gen.url, &stats.calls)
 			if err != nil {
 				return "", err
 			}
@@ -110,6 +184,8 @@ func generate(ctx context.Context) (string, error) {
 		}
 		i++
 	}
+	stats.iterations = i - 1
+
 	span.AddEvent("shuffling_password")
 	rand.Shuffle(
 		len(password), func(i, j int) {
@@ -120,12 +196,16 @@ func generate(ctx context.Context) (string, error) {
 	if len(password) > passwordLength {
 		span.AddEvent("trimming_password", trace.WithAttributes(attribute.Int("password.length", passwordLength)))
 		password = password[0:passwordLength]
+		stats.trimmed = true
 	}
 
-	return strings.Join(password, ""), nil
+	result := strings.Join(password, "")
+	metrics.record(spctx, result, stats)
+
+	return result, nil
 }
 
-func getChars(ctx context.Context, spanName, url string) ([]string, error) {
+func getChars(ctx context.Context, spanName, url string, calls *int) ([]string, error) {
 	spctx, span := tracer.Start(ctx, spanName, trace.WithSpanKind(trace.SpanKindInternal))
 	defer span.End()
 
@@ -134,15 +214,12 @@ func getChars(ctx context.Context, spanName, url string) ([]string, error) {
 		log.Printf("%s, iteration_loop_%d\n", spanName, i)
 		span.AddEvent(fmt.Sprintf("iteration_%d", i), trace.WithAttributes(attribute.Int("iteration", i)))
 
-		var resp struct {
-			Char string `json:"char"`
-		}
-
-		if err := web.GetJSON(spctx, url, &resp); err != nil {
+		char, err := fetchChar(spctx, url, calls)
+		if err != nil {
 			return nil, fmt.Errorf("failed to fetch url '%s': %w", url, err)
 		}
 
-		x = append(x, resp.Char)
+		x = append(x, char)
 	}
 
 	if len(x) == 0 {
@@ -152,6 +229,31 @@ func getChars(ctx context.Context, spanName, url string) ([]string, error) {
 	return x, nil
 }
 
-func work(mean, sigma float64) {
-	time.Sleep(time.Duration(random.Normalvariate(mean, sigma)))
+// fetchChar calls the charset service, retrying timeouts and the failures reported as retryable.
+// Every attempt is added to calls.
+func fetchChar(ctx context.Context, url string, calls *int) (string, error) {
+	for attempt := 1; ; attempt++ {
+		*calls++
+		resp, err := web.Get[charResponse](web.ContextWithAttempt(ctx, attempt), httpClient, url)
+		if err == nil {
+			return resp.Char, nil
+		}
+
+		// only the timeouts of the call are retried, not the ones of the caller's deadline
+		if ctx.Err() != nil || !(web.IsRetryable(err) || web.IsTimeout(err)) || attempt == maxAttempts {
+			return "", err
+		}
+
+		trace.SpanFromContext(ctx).AddEvent(
+			"retrying_char", trace.WithAttributes(attribute.Int("attempt", attempt)),
+		)
+	}
+}
+
+func work(ctx context.Context, mean, sigma float64) {
+	telemetry.Do(
+		ctx, func(context.Context) {
+			time.Sleep(time.Duration(random.Normalvariate(mean, sigma)))
+		},
+	)
 }
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/username/otel-playground/internal/lib/telemetry"
+)
+
+// passwordViews keeps one bucket per few characters, the default boundaries are made for latencies.
+var passwordViews = []telemetry.View{
+	{Instrument: "password.length", Buckets: []float64{8, 10, 12, 14, 16, 18, 20, 22, 24, 25, 32, 48, 64}},
+	{Instrument: "password.iterations", Buckets: []float64{1, 2, 3, 4, 5, 6, 8, 10, 15, 20}},
+	{Instrument: "password.downstream_calls", Buckets: []float64{4, 8, 12, 16, 20, 25, 30, 40, 50, 75, 100}},
+}
+
+type passwordMetrics struct {
+	generated       metric.Int64Counter
+	length          metric.Int64Histogram
+	chars           metric.Int64Counter
+	iterations      metric.Int64Histogram
+	trims           metric.Int64Counter
+	downstreamCalls metric.Int64Histogram
+}
+
+var metrics passwordMetrics
+
+func newPasswordMetrics() (passwordMetrics, error) {
+	meter := otel.Meter("main")
+
+	var m passwordMetrics
+	var err error
+
+	if m.generated, err = meter.Int64Counter(
+		"passwords.generated",
+		metric.WithDescription("generated passwords"),
+		metric.WithUnit("{password}"),
+	); err != nil {
+		return m, fmt.Errorf("failed to create passwords.generated: %w", err)
+	}
+
+	if m.length, err = meter.Int64Histogram(
+		"password.length",
+		metric.WithDescription("length of the generated passwords"),
+		metric.WithUnit("{char}"),
+	); err != nil {
+		return m, fmt.Errorf("failed to create password.length: %w", err)
+	}
+
+	if m.chars, err = meter.Int64Counter(
+		"password.chars",
+		metric.WithDescription("characters of the generated passwords per class"),
+		metric.WithUnit("{char}"),
+	); err != nil {
+		return m, fmt.Errorf("failed to create password.chars: %w", err)
+	}
+
+	if m.iterations, err = meter.Int64Histogram(
+		"password.iterations",
+		metric.WithDescription("loops over the charset services needed to reach the password length"),
+		metric.WithUnit("{iteration}"),
+	); err != nil {
+		return m, fmt.Errorf("failed to create password.iterations: %w", err)
+	}
+
+	if m.trims, err = meter.Int64Counter(
+		"password.trims",
+		metric.WithDescription("passwords trimmed because the last loop fetched more characters than needed"),
+		metric.WithUnit("{password}"),
+	); err != nil {
+		return m, fmt.Errorf("failed to create password.trims: %w", err)
+	}
+
+	if m.downstreamCalls, err = meter.Int64Histogram(
+		"password.downstream_calls",
+		metric.WithDescription("calls to the charset services per password, retries included"),
+		metric.WithUnit("{call}"),
+	); err != nil {
+		return m, fmt.Errorf("failed to create password.downstream_calls: %w", err)
+	}
+
+	return m, nil
+}
+
+// passwordStats is filled while a password is generated and recorded once it is complete.
+type passwordStats struct {
+	randomLength bool
+	iterations   int
+	calls        int
+	trimmed      bool
+}
+
+func (m passwordMetrics) record(ctx context.Context, password string, stats passwordStats) {
+	lengthSource := "requested"
+	if stats.randomLength {
+		lengthSource = "random"
+	}
+	withSource := metric.WithAttributes(attribute.String("password.length_source", lengthSource))
+
+	m.generated.Add(ctx, 1, withSource)
+	m.length.Record(ctx, int64(utf8.RuneCountInString(password)), withSource)
+	m.iterations.Record(ctx, int64(stats.iterations))
+	m.downstreamCalls.Record(ctx, int64(stats.calls))
+
+	if stats.trimmed {
+		m.trims.Add(ctx, 1)
+	}
+
+	perClass := map[string]int64{}
+	for _, char := range password {
+		perClass[charClass(char)]++
+	}
+	for class, count := range perClass {
+		m.chars.Add(ctx, count, metric.WithAttributes(attribute.String("char.class", class)))
+	}
+}
+
+func charClass(char rune) string {
+	switch {
+	case unicode.IsUpper(char):
+		return "upper"
+	case unicode.IsLower(char):
+		return "lower"
+	case unicode.IsDigit(char):
+		return "digit"
+	default:
+		return "special"
+	}
+}
@@ -7,14 +7,26 @@ import (
 	"os/signal"
 	"time"
 
+	"github.com/username/otel-playground/internal/lib/certs"
 	"github.com/username/otel-playground/internal/lib/environment"
 	"github.com/username/otel-playground/internal/lib/random"
+	"github.com/username/otel-playground/internal/lib/redact"
 	"github.com/username/otel-playground/internal/lib/web"
 )
 
 func main() {
 	url := environment.Get("GENERATOR_URL", "http://generator:5000/")
 
+	// the passwords are masked in the logs unless REDACTION_MODE=unsafe
+	redactor := redact.FromEnv(redact.Rule{Pattern: "password", Action: redact.Mask})
+	log.SetOutput(redactor.Writer(os.Stderr))
+
+	clientTLS, err := certs.FromEnv().ClientTLS()
+	if err != nil {
+		log.Fatalf("failed to load client certificates: %v\n", err)
+	}
+	client := web.NewClient(web.WithTimeout(10*time.Second), web.WithTLSConfig(clientTLS))
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
 
@@ -23,21 +35,22 @@ func main() {
 		case <-quit:
 			os.Exit(0)
 		case <-time.After(time.Duration(random.NumberInRange(351, 795)) * time.Millisecond):
-			getPassword(url)
+			getPassword(client, url)
 		}
 	}
 }
 
-func getPassword(url string) {
-	var res struct {
-		Password string `json:"password"`
-		Cause    string `json:"cause"`
-	}
+// users are sent in the X-User header, the services propagate them as the username baggage
+var users = []string{"donuts", "bagels", "muffins"}
 
-	if err := web.GetJSON(context.Background(), url, &res); err != nil {
+func getPassword(client *web.Client, url string) {
+	res, err := web.Get[struct {
+		Password string `json:"password"`
+	}](context.Background(), client, url, web.WithHeader("X-User", random.Choice(users)))
+	if err != nil {
 		log.Printf("failed to get password: %v\n", err)
 		return
 	}
 
-	log.Printf("got password '%s'\n", res.Password)
+	log.Printf("got password='%s'\n", res.Password)
 }
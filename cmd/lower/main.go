@@ -13,6 +13,8 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 
+	"github.com/username/otel-playground/internal/lib/certs"
+	"github.com/username/otel-playground/internal/lib/chars"
 	"github.com/username/otel-playground/internal/lib/environment"
 	"github.com/username/otel-playground/internal/lib/random"
 	"github.com/username/otel-playground/internal/lib/telemetry"
@@ -26,6 +28,8 @@ const (
 
 var tracer trace.Tracer
 
+var charsServed *chars.Counter
+
 var letters = []rune{
 	'a',
 	'b',
@@ -57,37 +61,75 @@ var letters = []rune{
 
 var digitURL = environment.Get("DIGIT_URL", "http://digit:5000/")
 
+var httpClient *web.Client
+
 func init() {
 	rand.Seed(time.Now().Unix())
 }
 
 func main() {
-	var port int
+	var port, adminPort int
 	flag.IntVar(&port, "port", 5000, "The port to listen on")
+	flag.IntVar(&adminPort, "admin-port", 0, "The port to serve pprof and runtime introspection on, 0 disables it")
 	flag.Parse()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	tlsConfig := certs.FromEnv()
+	serverTLS, err := tlsConfig.ServerTLS()
+	if err != nil {
+		log.Fatalf("failed to load server certificates: %v\n", err)
+	}
+	clientTLS, err := tlsConfig.ClientTLS()
+	if err != nil {
+		log.Fatalf("failed to load client certificates: %v\n", err)
+	}
+
+	baggagePolicy := web.BaggagePolicyFromEnv()
+	redactor := chars.Redactor()
+
 	client, err := telemetry.Configure(
 		ctx,
 		telemetry.WithServiceName(serviceName),
 		telemetry.WithServiceVersion(serviceVersion),
+		telemetry.WithExporterTLS(clientTLS),
+		telemetry.WithProfiling(telemetry.ProfilingConfigFromEnv()),
+		telemetry.WithTailSampling(telemetry.TailSamplingConfigFromEnv()),
+		telemetry.WithExporter(telemetry.SignalTraces, telemetry.ExportersFromEnv(telemetry.SignalTraces)...),
+		telemetry.WithExporter(telemetry.SignalMetrics, telemetry.ExportersFromEnv(telemetry.SignalMetrics)...),
+		telemetry.WithPrometheusEndpoint(environment.Get("PROMETHEUS_ENDPOINT", "")),
+		telemetry.WithStrict(environment.Get("TELEMETRY_STRICT", false)),
+		telemetry.WithSpanBaggage(baggagePolicy.Promote...),
+		telemetry.WithRedactor(redactor),
 	)
 	if err != nil {
 		log.Fatalf("failed to register tracer: %v\n", err)
 	}
-	defer func() {
-		client.Shutdown(context.Background())
-	}()
 
 	tracer = otel.Tracer("main")
 
-	mux := http.NewServeMux()
-	web.Handler(mux, "/", http.HandlerFunc(lowerHandler))
-	web.HealthCheckHandler(mux, serviceName, serviceVersion)
-
-	if err := web.Server(port, mux, serviceName, web.FilterURLs{"/healthcheck"}); err != nil {
+	if charsServed, err = chars.NewCounter(otel.Meter("main"), redactor); err != nil {
+		log.Fatalf("failed to create metrics: %v\n", err)
+	}
+	httpClient = web.NewClient(web.WithTimeout(5*time.Second), web.WithTLSConfig(clientTLS))
+
+	router := web.NewRouter()
+	router.Get("/", lowerHandler)
+	web.HealthCheckHandler(router, serviceName, serviceVersion)
+
+	srv := web.NewServer(
+		router,
+		web.WithPort(port),
+		web.WithAdminPort(adminPort),
+		web.WithName(serviceName),
+		web.WithFilters(web.FilterURLs{"/healthcheck"}),
+		web.WithTLS(serverTLS),
+		web.WithTelemetry(client),
+		web.WithBaggagePolicy(baggagePolicy),
+		web.WithResponseLogging(environment.Get("LOG_RESPONSES", false), redactor),
+	)
+	if err := srv.Run(); err != nil {
 		log.Fatalf("failed to start server: %v\n", err)
 	}
 }
@@ -95,9 +137,10 @@ func main() {
 func lowerHandler(w http.ResponseWriter, r *http.Request) {
 	char, err := randomLower(r.Context())
 	if err != nil {
-		web.ServerErrorResponse(w, err)
+		web.ErrorResponse(w, r, web.NewUpstreamError("failed to get a lower char", err))
 		return
 	}
+	charsServed.Add(r.Context(), char)
 
 	web.WriteJSON(w, http.StatusOK, web.Envelope{"char": string(char)})
 }
@@ -129,11 +172,10 @@ func getDigit(ctx context.Context, char rune) (string, error) {
 	)
 	defer span.End()
 
-	var res struct {
+	res, err := web.Get[struct {
 		Char string `json:"char"`
-	}
-
-	if err := web.GetJSON(spctx, digitURL, &res); err != nil {
+	}](spctx, httpClient, digitURL)
+	if err != nil {
 		return "", fmt.Errorf("failed to fetch digit: %w", err)
 	}
 
@@ -141,12 +183,17 @@ func getDigit(ctx context.Context, char rune) (string, error) {
 }
 
 func work(ctx context.Context, await float64, spanName string, char rune) {
-	_, span := tracer.Start(
+	ctx, span := tracer.Start(
 		ctx,
 		spanName,
 		trace.WithAttributes(attribute.String("char", string(char))),
 		trace.WithSpanKind(trace.SpanKindInternal),
 	)
-	time.Sleep(time.Duration(await))
-	span.End()
+	defer span.End()
+
+	telemetry.Do(
+		ctx, func(context.Context) {
+			time.Sleep(time.Duration(await))
+		},
+	)
 }
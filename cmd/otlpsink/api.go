@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/username/otel-playground/internal/lib/otlpjson"
+	"github.com/username/otel-playground/internal/lib/web"
+)
+
+// parseFilter reads the trace_id, service, name, start, end and limit query parameters.
+// start and end are RFC 3339 times or durations before now, e.g. start=15m.
+func parseFilter(r *http.Request) (filter, error) {
+	query := r.URL.Query()
+	f := filter{
+		traceID: query.Get("trace_id"),
+		service: query.Get("service"),
+		name:    query.Get("name"),
+		limit:   100,
+	}
+
+	var err error
+	if f.start, err = parseTime(query.Get("start")); err != nil {
+		return f, fmt.Errorf("invalid start: %w", err)
+	}
+	if f.end, err = parseTime(query.Get("end")); err != nil {
+		return f, fmt.Errorf("invalid end: %w", err)
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		if f.limit, err = strconv.Atoi(limit); err != nil || f.limit < 0 {
+			return f, fmt.Errorf("invalid limit '%s'", limit)
+		}
+	}
+
+	return f, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// apiRoutes serves the queries on the stored telemetry
+func apiRoutes(router *web.Router, s *store) {
+	router.Get(
+		"/api/traces", func(w http.ResponseWriter, r *http.Request) {
+			f, err := parseFilter(r)
+			if err != nil {
+				web.BadRequestResponse(w, r, err)
+				return
+			}
+			web.WriteJSON(w, http.StatusOK, web.Envelope{"traces": s.findTraces(f)})
+		},
+	)
+
+	router.Get(
+		"/api/traces/{traceID}", func(w http.ResponseWriter, r *http.Request) {
+			spans := s.findSpans(filter{traceID: web.PathParam(r, "traceID")})
+			if len(spans) == 0 {
+				web.NotFoundResponse(w, r)
+				return
+			}
+			web.WriteJSON(w, http.StatusOK, web.Envelope{"trace": otlpjson.Summarize(spans), "spans": spans})
+		},
+	)
+
+	router.Get(
+		"/api/spans", func(w http.ResponseWriter, r *http.Request) {
+			f, err := parseFilter(r)
+			if err != nil {
+				web.BadRequestResponse(w, r, err)
+				return
+			}
+			web.WriteJSON(w, http.StatusOK, web.Envelope{"spans": s.findSpans(f)})
+		},
+	)
+
+	router.Get(
+		"/api/metrics", func(w http.ResponseWriter, r *http.Request) {
+			f, err := parseFilter(r)
+			if err != nil {
+				web.BadRequestResponse(w, r, err)
+				return
+			}
+			web.WriteJSON(w, http.StatusOK, web.Envelope{"points": s.findPoints(f)})
+		},
+	)
+}
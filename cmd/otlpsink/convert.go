@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/username/otel-playground/internal/lib/otlpjson"
+)
+
+// tracesData converts the received spans to the OTLP/JSON encoding written to the traces file
+func tracesData(resourceSpans []*tracepb.ResourceSpans) otlpjson.TracesData {
+	var data otlpjson.TracesData
+	for _, rs := range resourceSpans {
+		r := otlpjson.ResourceSpans{
+			Resource:  otlpjson.Resource{Attributes: keyValues(rs.GetResource().GetAttributes())},
+			SchemaURL: rs.GetSchemaUrl(),
+		}
+
+		for _, ss := range rs.GetScopeSpans() {
+			s := otlpjson.ScopeSpans{
+				Scope: otlpjson.Scope{Name: ss.GetScope().GetName(), Version: ss.GetScope().GetVersion()},
+			}
+
+			for _, span := range ss.GetSpans() {
+				s.Spans = append(s.Spans, spanData(span))
+			}
+			r.ScopeSpans = append(r.ScopeSpans, s)
+		}
+		data.ResourceSpans = append(data.ResourceSpans, r)
+	}
+
+	return data
+}
+
+func spanData(span *tracepb.Span) otlpjson.SpanData {
+	s := otlpjson.SpanData{
+		TraceID:           hex.EncodeToString(span.GetTraceId()),
+		SpanID:            hex.EncodeToString(span.GetSpanId()),
+		ParentSpanID:      hex.EncodeToString(span.GetParentSpanId()),
+		Name:              span.GetName(),
+		Kind:              int(span.GetKind()),
+		StartTimeUnixNano: strconv.FormatUint(span.GetStartTimeUnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatUint(span.GetEndTimeUnixNano(), 10),
+		Attributes:        keyValues(span.GetAttributes()),
+		Status:            otlpjson.Status{Code: int(span.GetStatus().GetCode()), Message: span.GetStatus().GetMessage()},
+	}
+
+	for _, event := range span.GetEvents() {
+		s.Events = append(
+			s.Events, otlpjson.Event{
+				TimeUnixNano: strconv.FormatUint(event.GetTimeUnixNano(), 10),
+				Name:         event.GetName(),
+				Attributes:   keyValues(event.GetAttributes()),
+			},
+		)
+	}
+
+	return s
+}
+
+func keyValues(kvs []*commonpb.KeyValue) []otlpjson.KeyValue {
+	result := make([]otlpjson.KeyValue, 0, len(kvs))
+	for _, kv := range kvs {
+		result = append(result, otlpjson.KeyValue{Key: kv.GetKey(), Value: anyValue(kv.GetValue())})
+	}
+	return result
+}
+
+// anyValue converts the value, the arrays and maps are kept as their json encoding in a string
+func anyValue(v *commonpb.AnyValue) otlpjson.AnyValue {
+	switch value := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return otlpjson.AnyValue{StringValue: &value.StringValue}
+	case *commonpb.AnyValue_BoolValue:
+		return otlpjson.AnyValue{BoolValue: &value.BoolValue}
+	case *commonpb.AnyValue_IntValue:
+		i := strconv.FormatInt(value.IntValue, 10)
+		return otlpjson.AnyValue{IntValue: &i}
+	case *commonpb.AnyValue_DoubleValue:
+		return otlpjson.AnyValue{DoubleValue: &value.DoubleValue}
+	case *commonpb.AnyValue_ArrayValue:
+		values := make([]string, 0, len(value.ArrayValue.GetValues()))
+		for _, item := range value.ArrayValue.GetValues() {
+			values = append(values, anyValue(item).String())
+		}
+		encoded, _ := json.Marshal(values)
+		s := string(encoded)
+		return otlpjson.AnyValue{StringValue: &s}
+	case *commonpb.AnyValue_KvlistValue:
+		values := map[string]string{}
+		for _, kv := range value.KvlistValue.GetValues() {
+			values[kv.GetKey()] = anyValue(kv.GetValue()).String()
+		}
+		encoded, _ := json.Marshal(values)
+		s := string(encoded)
+		return otlpjson.AnyValue{StringValue: &s}
+	default:
+		return otlpjson.AnyValue{}
+	}
+}
+
+// points flattens the data points of the metrics
+func points(resourceMetrics []*metricpb.ResourceMetrics) []otlpjson.Point {
+	var result []otlpjson.Point
+	for _, rm := range resourceMetrics {
+		service := attributes(rm.GetResource().GetAttributes())["service.name"]
+
+		for _, sm := range rm.GetScopeMetrics() {
+			for _, m := range sm.GetMetrics() {
+				newPoint := func(typ string, timeUnixNano uint64, attrs []*commonpb.KeyValue) otlpjson.Point {
+					return otlpjson.Point{
+						Time:       time.Unix(0, int64(timeUnixNano)),
+						Service:    service,
+						Name:       m.GetName(),
+						Unit:       m.GetUnit(),
+						Type:       typ,
+						Attributes: attributes(attrs),
+					}
+				}
+
+				switch data := m.GetData().(type) {
+				case *metricpb.Metric_Gauge:
+					for _, dp := range data.Gauge.GetDataPoints() {
+						p := newPoint("gauge", dp.GetTimeUnixNano(), dp.GetAttributes())
+						p.Value = numberValue(dp)
+						result = append(result, p)
+					}
+				case *metricpb.Metric_Sum:
+					for _, dp := range data.Sum.GetDataPoints() {
+						p := newPoint("sum", dp.GetTimeUnixNano(), dp.GetAttributes())
+						p.Value = numberValue(dp)
+						result = append(result, p)
+					}
+				case *metricpb.Metric_Histogram:
+					for _, dp := range data.Histogram.GetDataPoints() {
+						p := newPoint("histogram", dp.GetTimeUnixNano(), dp.GetAttributes())
+						p.Count, p.Sum = dp.GetCount(), dp.GetSum()
+						p.Bounds, p.BucketCounts = dp.GetExplicitBounds(), dp.GetBucketCounts()
+						result = append(result, p)
+					}
+				case *metricpb.Metric_ExponentialHistogram:
+					for _, dp := range data.ExponentialHistogram.GetDataPoints() {
+						p := newPoint("exponential_histogram", dp.GetTimeUnixNano(), dp.GetAttributes())
+						p.Count, p.Sum = dp.GetCount(), dp.GetSum()
+						result = append(result, p)
+					}
+				case *metricpb.Metric_Summary:
+					for _, dp := range data.Summary.GetDataPoints() {
+						p := newPoint("summary", dp.GetTimeUnixNano(), dp.GetAttributes())
+						p.Count, p.Sum = dp.GetCount(), dp.GetSum()
+						result = append(result, p)
+					}
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+func numberValue(dp *metricpb.NumberDataPoint) float64 {
+	if value, ok := dp.GetValue().(*metricpb.NumberDataPoint_AsInt); ok {
+		return float64(value.AsInt)
+	}
+	return dp.GetAsDouble()
+}
+
+func attributes(kvs []*commonpb.KeyValue) map[string]string {
+	if len(kvs) == 0 {
+		return nil
+	}
+
+	attrs := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		attrs[kv.GetKey()] = anyValue(kv.GetValue()).String()
+	}
+	return attrs
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	// the services compress their exports with gzip
+	_ "google.golang.org/grpc/encoding/gzip"
+
+	"github.com/username/otel-playground/internal/lib/web"
+)
+
+const (
+	serviceName    = "otlpsink"
+	serviceVersion = "1.0.0"
+)
+
+// otlpsink stands in for the collector in development: it receives the traces and metrics over OTLP gRPC and HTTP,
+// keeps the last ones in memory for the query API and appends them to JSONL files.
+// It doesn't instrument itself, its spans would be exported to itself.
+func main() {
+	var grpcPort, httpPort, maxSpans, maxPoints int
+	var dir string
+	flag.IntVar(&grpcPort, "grpc-port", 4317, "The port receiving OTLP over gRPC")
+	flag.IntVar(&httpPort, "http-port", 4318, "The port receiving OTLP over HTTP and serving the query API")
+	flag.IntVar(&maxSpans, "max-spans", 100000, "The number of spans kept in memory")
+	flag.IntVar(&maxPoints, "max-points", 100000, "The number of metric points kept in memory")
+	flag.StringVar(&dir, "dir", "", "The directory of the traces.jsonl and metrics.jsonl files, empty keeps them in memory only")
+	flag.Parse()
+
+	if maxSpans < 1 || maxPoints < 1 {
+		log.Fatalf("max-spans and max-points must be at least 1\n")
+	}
+
+	s, err := newStore(maxSpans, maxPoints, dir)
+	if err != nil {
+		log.Fatalf("failed to create the store: %v\n", err)
+	}
+	defer s.close()
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", grpcPort))
+	if err != nil {
+		log.Fatalf("failed to listen on the grpc port: %v\n", err)
+	}
+
+	grpcServer := grpc.NewServer(grpc.MaxRecvMsgSize(maxBodySize))
+	coltracepb.RegisterTraceServiceServer(grpcServer, traceService{store: s})
+	colmetricpb.RegisterMetricsServiceServer(grpcServer, metricsService{store: s})
+
+	go func() {
+		log.Printf("grpc port: %d", grpcPort)
+		if err := grpcServer.Serve(listener); err != nil {
+			log.Printf("grpc server failed: %v\n", err)
+		}
+	}()
+
+	router := web.NewRouter()
+	router.Post("/v1/traces", tracesHandler(s))
+	router.Post("/v1/metrics", metricsHandler(s))
+	apiRoutes(router, s)
+	web.HealthCheckHandler(router, serviceName, serviceVersion)
+
+	srv := web.NewServer(
+		router,
+		web.WithPort(httpPort),
+		web.WithName(serviceName),
+		web.WithFilters(web.FilterURLs{"/healthcheck", "/v1/"}),
+	)
+	err = srv.Run()
+
+	grpcServer.GracefulStop()
+
+	if err != nil {
+		log.Fatalf("failed to start server: %v\n", err)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/username/otel-playground/internal/lib/web"
+)
+
+// maxBodySize bounds the OTLP/HTTP requests
+const maxBodySize = 32 << 20
+
+type traceService struct {
+	coltracepb.UnimplementedTraceServiceServer
+	store *store
+}
+
+func (s traceService) Export(
+	_ context.Context, req *coltracepb.ExportTraceServiceRequest,
+) (*coltracepb.ExportTraceServiceResponse, error) {
+	if err := s.store.addTraces(tracesData(req.GetResourceSpans())); err != nil {
+		log.Printf("failed to store the spans: %v\n", err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+type metricsService struct {
+	colmetricpb.UnimplementedMetricsServiceServer
+	store *store
+}
+
+func (s metricsService) Export(
+	_ context.Context, req *colmetricpb.ExportMetricsServiceRequest,
+) (*colmetricpb.ExportMetricsServiceResponse, error) {
+	if err := s.store.addPoints(points(req.GetResourceMetrics())); err != nil {
+		log.Printf("failed to store the metrics: %v\n", err)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &colmetricpb.ExportMetricsServiceResponse{}, nil
+}
+
+// tracesHandler receives the spans on /v1/traces, encoded as protobuf or as json
+func tracesHandler(s *store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req coltracepb.ExportTraceServiceRequest
+		if err := decodeRequest(r, &req); err != nil {
+			web.BadRequestResponse(w, r, err)
+			return
+		}
+
+		if _, err := (traceService{store: s}).Export(r.Context(), &req); err != nil {
+			web.ServerErrorResponse(w, r, err)
+			return
+		}
+		writeResponse(w, r, &coltracepb.ExportTraceServiceResponse{})
+	}
+}
+
+// metricsHandler receives the metrics on /v1/metrics, encoded as protobuf or as json
+func metricsHandler(s *store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req colmetricpb.ExportMetricsServiceRequest
+		if err := decodeRequest(r, &req); err != nil {
+			web.BadRequestResponse(w, r, err)
+			return
+		}
+
+		if _, err := (metricsService{store: s}).Export(r.Context(), &req); err != nil {
+			web.ServerErrorResponse(w, r, err)
+			return
+		}
+		writeResponse(w, r, &colmetricpb.ExportMetricsServiceResponse{})
+	}
+}
+
+func isJSON(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+}
+
+func decodeRequest(r *http.Request, msg proto.Message) error {
+	var body io.Reader = http.MaxBytesReader(nil, r.Body, maxBodySize)
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return fmt.Errorf("invalid gzip body: %w", err)
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	// the limit applies to the decompressed body too, a small gzip body can expand to gigabytes
+	data, err := io.ReadAll(io.LimitReader(body, maxBodySize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read the body: %w", err)
+	}
+	if len(data) > maxBodySize {
+		return fmt.Errorf("the body is larger than %d bytes", maxBodySize)
+	}
+
+	if !isJSON(r) {
+		return proto.Unmarshal(data, msg)
+	}
+
+	if data, err = base64IDs(data); err != nil {
+		return err
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, msg)
+}
+
+func writeResponse(w http.ResponseWriter, r *http.Request, msg proto.Message) {
+	var data []byte
+	if isJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		data, _ = protojson.Marshal(msg)
+	} else {
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		data, _ = proto.Marshal(msg)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+// idKeys are the fields OTLP/JSON encodes as hex instead of the base64 of the protobuf json mapping
+var idKeys = map[string]bool{"traceId": true, "spanId": true, "parentSpanId": true}
+
+// base64IDs re-encodes the hex ids of an OTLP/JSON body so protojson can decode it
+func base64IDs(data []byte) ([]byte, error) {
+	var body interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("invalid json body: %w", err)
+	}
+
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			for key, item := range value {
+				if id, ok := item.(string); ok && idKeys[key] {
+					if raw, err := hex.DecodeString(id); err == nil {
+						value[key] = base64.StdEncoding.EncodeToString(raw)
+					}
+					continue
+				}
+				walk(item)
+			}
+		case []interface{}:
+			for _, item := range value {
+				walk(item)
+			}
+		}
+	}
+	walk(body)
+
+	return json.Marshal(body)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/username/otel-playground/internal/lib/otlpjson"
+)
+
+// ring keeps the last items added, the oldest ones are overwritten once it is full
+type ring[T any] struct {
+	items []T
+	next  int
+	full  bool
+}
+
+func newRing[T any](size int) *ring[T] {
+	return &ring[T]{items: make([]T, size)}
+}
+
+func (r *ring[T]) add(item T) {
+	r.items[r.next] = item
+	r.next = (r.next + 1) % len(r.items)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// each calls fn from the oldest to the newest item
+func (r *ring[T]) each(fn func(T)) {
+	if r.full {
+		for _, item := range r.items[r.next:] {
+			fn(item)
+		}
+	}
+	for _, item := range r.items[:r.next] {
+		fn(item)
+	}
+}
+
+// filter selects the spans and points, the zero values match everything
+type filter struct {
+	traceID string
+	service string
+	name    string
+	start   time.Time
+	end     time.Time
+	limit   int
+}
+
+func (f filter) match(traceID, service, name string, start, end time.Time) bool {
+	return (f.traceID == "" || f.traceID == traceID) &&
+		(f.service == "" || f.service == service) &&
+		(f.name == "" || f.name == name) &&
+		(f.start.IsZero() || !end.Before(f.start)) &&
+		(f.end.IsZero() || !start.After(f.end))
+}
+
+// store keeps the last spans and metric points in memory and appends them to JSONL files when dir is set
+type store struct {
+	mu      sync.RWMutex
+	spans   *ring[otlpjson.Span]
+	points  *ring[otlpjson.Point]
+	traces  *os.File
+	metrics *os.File
+}
+
+func newStore(maxSpans, maxPoints int, dir string) (*store, error) {
+	s := &store{spans: newRing[otlpjson.Span](maxSpans), points: newRing[otlpjson.Point](maxPoints)}
+	if dir == "" {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create the data dir: %w", err)
+	}
+
+	var err error
+	if s.traces, err = openAppend(filepath.Join(dir, "traces.jsonl")); err != nil {
+		return nil, err
+	}
+	if s.metrics, err = openAppend(filepath.Join(dir, "metrics.jsonl")); err != nil {
+		s.traces.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func openAppend(name string) (*os.File, error) {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	return f, nil
+}
+
+func (s *store) close() {
+	if s.traces != nil {
+		s.traces.Close()
+		s.metrics.Close()
+	}
+}
+
+// addTraces stores the spans, the traces file gets one OTLP/JSON line per export
+func (s *store) addTraces(data otlpjson.TracesData) error {
+	spans := data.Spans()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, span := range spans {
+		s.spans.add(span)
+	}
+
+	if s.traces == nil {
+		return nil
+	}
+	return writeLine(s.traces, data)
+}
+
+// addPoints stores the points, the metrics file gets one line per point
+func (s *store) addPoints(points []otlpjson.Point) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range points {
+		s.points.add(p)
+		if s.metrics != nil {
+			if err := writeLine(s.metrics, p); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func writeLine(f *os.File, v interface{}) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// findSpans returns the matching spans sorted by start time, the last ones when over the limit
+func (s *store) findSpans(f filter) []otlpjson.Span {
+	s.mu.RLock()
+	var spans []otlpjson.Span
+	s.spans.each(
+		func(span otlpjson.Span) {
+			if f.match(span.TraceID, span.Service, span.Name, span.Start, span.End) {
+				spans = append(spans, span)
+			}
+		},
+	)
+	s.mu.RUnlock()
+
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].Start.Before(spans[j].Start) })
+
+	return last(spans, f.limit)
+}
+
+// findTraces returns the traces with a span matching the filter, from the oldest to the newest.
+// The spans are collected under a single lock so that a trace can't be evicted between its match and its summary.
+func (s *store) findTraces(f filter) []otlpjson.TraceSummary {
+	s.mu.RLock()
+
+	firstMatch := map[string]time.Time{}
+	s.spans.each(
+		func(span otlpjson.Span) {
+			if !f.match(span.TraceID, span.Service, span.Name, span.Start, span.End) {
+				return
+			}
+			if start, ok := firstMatch[span.TraceID]; !ok || span.Start.Before(start) {
+				firstMatch[span.TraceID] = span.Start
+			}
+		},
+	)
+
+	ids := make([]string, 0, len(firstMatch))
+	for id := range firstMatch {
+		ids = append(ids, id)
+	}
+	sort.Slice(
+		ids, func(i, j int) bool {
+			if a, b := firstMatch[ids[i]], firstMatch[ids[j]]; !a.Equal(b) {
+				return a.Before(b)
+			}
+			return ids[i] < ids[j]
+		},
+	)
+	ids = last(ids, f.limit)
+
+	byTrace := make(map[string][]otlpjson.Span, len(ids))
+	for _, id := range ids {
+		byTrace[id] = nil
+	}
+	s.spans.each(
+		func(span otlpjson.Span) {
+			if spans, ok := byTrace[span.TraceID]; ok {
+				byTrace[span.TraceID] = append(spans, span)
+			}
+		},
+	)
+
+	s.mu.RUnlock()
+
+	summaries := make([]otlpjson.TraceSummary, 0, len(ids))
+	for _, id := range ids {
+		summaries = append(summaries, otlpjson.Summarize(byTrace[id]))
+	}
+
+	return summaries
+}
+
+func (s *store) findPoints(f filter) []otlpjson.Point {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var points []otlpjson.Point
+	s.points.each(
+		func(p otlpjson.Point) {
+			if f.match("", p.Service, p.Name, p.Time, p.Time) {
+				points = append(points, p)
+			}
+		},
+	)
+
+	return last(points, f.limit)
+}
+
+func last[T any](items []T, limit int) []T {
+	if limit > 0 && len(items) > limit {
+		return items[len(items)-limit:]
+	}
+	return items
+}
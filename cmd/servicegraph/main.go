@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/username/otel-playground/internal/lib/otlpjson"
+	"github.com/username/otel-playground/internal/lib/servicegraph"
+)
+
+// servicegraph builds the graph of the calls between the services from spans exported as OTLP/JSON lines,
+// e.g. by the collector file exporter, and writes it as json or dot:
+//
+//	servicegraph -format dot traces.jsonl | dot -Tsvg > services.svg
+func main() {
+	var format string
+	flag.StringVar(&format, "format", "json", "The output format, json or dot")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-format json|dot] [file ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	spans, err := readSpans(flag.Args())
+	if err != nil {
+		log.Fatalf("failed to read the spans: %v\n", err)
+	}
+
+	graph := servicegraph.FromSpans(spans)
+
+	switch format {
+	case "json":
+		err = graph.WriteJSON(os.Stdout)
+	case "dot":
+		err = graph.WriteDOT(os.Stdout)
+	default:
+		log.Fatalf("unknown format '%s'\n", format)
+	}
+	if err != nil {
+		log.Fatalf("failed to write the graph: %v\n", err)
+	}
+}
+
+// readSpans reads the spans of the files, or of the standard input when there is none
+func readSpans(files []string) ([]otlpjson.Span, error) {
+	if len(files) == 0 {
+		return otlpjson.ReadSpans(os.Stdin)
+	}
+	return otlpjson.ReadFiles(files...)
+}
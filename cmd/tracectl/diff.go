@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/username/otel-playground/internal/lib/otlpjson"
+)
+
+// diffNode pairs the spans of two traces at the same place in their trees: same service and name under
+// paired parents, the siblings with the same service and name are paired in start order.
+type diffNode struct {
+	label    string
+	depth    int
+	a, b     *otlpjson.Span
+	children []*diffNode
+}
+
+func mergeTrees(a, b []*node) []*diffNode {
+	root := &diffNode{depth: -1}
+	root.merge(a, func(d *diffNode, span *otlpjson.Span) { d.a = span })
+	root.merge(b, func(d *diffNode, span *otlpjson.Span) { d.b = span })
+	return root.children
+}
+
+// merge pairs the nodes with the children of d, set records the span of the trace in the pair
+func (d *diffNode) merge(nodes []*node, set func(*diffNode, *otlpjson.Span)) {
+	seen := map[string]int{}
+	for _, n := range nodes {
+		key := n.span.Service + " " + n.span.Name
+		occurrence := seen[key]
+		seen[key]++
+
+		child := d.find(key, occurrence)
+		if child == nil {
+			child = &diffNode{label: n.span.Name, depth: d.depth + 1}
+			d.children = append(d.children, child)
+		}
+
+		set(child, &n.span)
+		child.merge(n.children, set)
+	}
+}
+
+// find returns the occurrence-th child with the service and name of key
+func (d *diffNode) find(key string, occurrence int) *diffNode {
+	for _, child := range d.children {
+		span := child.a
+		if span == nil {
+			span = child.b
+		}
+
+		if span.Service+" "+span.Name == key {
+			if occurrence == 0 {
+				return child
+			}
+			occurrence--
+		}
+	}
+	return nil
+}
+
+func (d *diffNode) service() string {
+	if d.a != nil {
+		return d.a.Service
+	}
+	return d.b.Service
+}
+
+// renderDiff writes the spans of both traces side by side with the change of their durations.
+// The spans only in a are marked with -, the ones only in b with + and the ones whose status changed with !.
+func renderDiff(w io.Writer, a, b []otlpjson.Span) error {
+	if len(a) == 0 || len(b) == 0 {
+		return fmt.Errorf("no span to compare")
+	}
+
+	aStart, aEnd := bounds(a)
+	bStart, bEnd := bounds(b)
+
+	if _, err := fmt.Fprintf(
+		w, "a: trace %s  %d spans  %s\nb: trace %s  %d spans  %s\n\n",
+		a[0].TraceID, len(a), formatDuration(aEnd.Sub(aStart)),
+		b[0].TraceID, len(b), formatDuration(bEnd.Sub(bStart)),
+	); err != nil {
+		return err
+	}
+
+	var (
+		lines                         []diffLine
+		removed, added, statusChanged int
+		walkDiff                      func([]*diffNode)
+	)
+	walkDiff = func(nodes []*diffNode) {
+		for _, d := range nodes {
+			mark := " "
+			durationA, durationB, change := "-", "-", ""
+
+			switch {
+			case d.b == nil:
+				mark = "-"
+				removed++
+				durationA = formatDuration(d.a.Duration())
+			case d.a == nil:
+				mark = "+"
+				added++
+				durationB = formatDuration(d.b.Duration())
+			default:
+				durationA, durationB = formatDuration(d.a.Duration()), formatDuration(d.b.Duration())
+				change = durationChange(d.a.Duration(), d.b.Duration())
+				if d.a.Failed != d.b.Failed {
+					mark = "!"
+					statusChanged++
+				}
+			}
+
+			lines = append(
+				lines, diffLine{
+					label:   mark + " " + strings.Repeat("  ", d.depth) + d.label,
+					service: d.service(),
+					a:       durationA,
+					b:       durationB,
+					change:  change,
+				},
+			)
+
+			walkDiff(d.children)
+		}
+	}
+	walkDiff(mergeTrees(buildTree(a), buildTree(b)))
+
+	if err := writeDiffLines(w, lines); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(
+		w, "\n%d spans only in a, %d spans only in b, %d status changes, total %s\n",
+		removed, added, statusChanged, durationChange(aEnd.Sub(aStart), bEnd.Sub(bStart)),
+	)
+	return err
+}
+
+// diffLine is a row of the diff, a pair of spans
+type diffLine struct {
+	label, service, a, b, change string
+}
+
+func writeDiffLines(w io.Writer, lines []diffLine) error {
+	lines = append([]diffLine{{label: "  SPAN", service: "SERVICE", a: "A", b: "B", change: "CHANGE"}}, lines...)
+
+	var labelWidth, serviceWidth, aWidth, bWidth int
+	for _, l := range lines {
+		labelWidth = max(labelWidth, len([]rune(l.label)))
+		serviceWidth = max(serviceWidth, len(l.service))
+		aWidth = max(aWidth, len([]rune(l.a)))
+		bWidth = max(bWidth, len([]rune(l.b)))
+	}
+
+	for _, l := range lines {
+		row := fmt.Sprintf(
+			"%s  %-*s  %s  %s  %s",
+			pad(l.label, labelWidth), serviceWidth, l.service, padLeft(l.a, aWidth), padLeft(l.b, bWidth), l.change,
+		)
+		if _, err := fmt.Fprintln(w, strings.TrimRight(row, " ")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// durationChange formats the difference from a to b, with its ratio to a
+func durationChange(a, b time.Duration) string {
+	delta := b - a
+	sign := "+"
+	if delta < 0 {
+		sign = "-"
+		delta = -delta
+	}
+
+	if a <= 0 {
+		return sign + formatDuration(delta)
+	}
+	return fmt.Sprintf("%s%s (%s%.0f%%)", sign, formatDuration(delta), sign, float64(delta)/float64(a)*100)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// tracectl renders the traces stored by the otlpsink, or exported as OTLP/JSON lines, in the terminal:
+//
+//	tracectl list -service generator -since 5m
+//	tracectl show -attrs http.route,char 4bf92f3577b34da6a3ce929d0e0e4736
+//	tracectl diff -file traces.jsonl 4bf92f3577b34da6a3ce929d0e0e4736 0af7651916cd43dd8448eb211c80319c
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("tracectl: ")
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch command, args := os.Args[1], os.Args[2:]; command {
+	case "list":
+		err = list(args)
+	case "show":
+		err = show(args)
+	case "diff":
+		err = diff(args)
+	case "help", "-h", "-help", "--help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command '%s'\n", command)
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		log.Fatalf("%v\n", err)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(
+		os.Stderr, `Usage: %s <command> [flags] [arguments]
+
+Commands:
+  list               lists the last traces
+  show [trace id]    renders the waterfall of a trace, the last one by default
+  diff <a> <b>       compares the structure and the timings of two traces
+
+Run '%[1]s <command> -h' for the flags of a command.
+`, os.Args[0],
+	)
+}
+
+func newFlagSet(name, arguments string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] %s\n", os.Args[0], name, arguments)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func list(args []string) error {
+	var (
+		src   source
+		query traceQuery
+		since time.Duration
+	)
+
+	fs := newFlagSet("list", "")
+	src.register(fs)
+	fs.StringVar(&query.service, "service", "", "Only the traces with a span of the service")
+	fs.StringVar(&query.name, "name", "", "Only the traces with a span of the name")
+	fs.DurationVar(&since, "since", 0, "Only the traces with a span ending in the period, e.g. 15m")
+	fs.IntVar(&query.limit, "limit", 20, "The number of traces listed")
+	_ = fs.Parse(args)
+
+	if since > 0 {
+		query.start = time.Now().Add(-since)
+	}
+
+	traces, err := src.traces(context.Background(), query)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TRACE ID\tSTART\tSERVICE\tNAME\tSPANS\tDURATION\tSTATUS")
+	for _, t := range traces {
+		status := "ok"
+		if t.Failed {
+			status = "error"
+		}
+		fmt.Fprintf(
+			w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			t.TraceID, t.Start.Local().Format(time.TimeOnly), t.Service, t.Name, t.Spans, t.Duration, status,
+		)
+	}
+
+	return w.Flush()
+}
+
+func show(args []string) error {
+	var (
+		src   source
+		opts  waterfallOptions
+		attrs string
+	)
+
+	fs := newFlagSet("show", "[trace id]")
+	src.register(fs)
+	fs.StringVar(&attrs, "attrs", "", "The comma separated attributes displayed after the spans, * for all of them")
+	fs.Func(
+		"where", "Only the spans with the attribute, as key or key=value, and their parents, can be repeated",
+		func(value string) error {
+			opts.where = append(opts.where, parseMatcher(value))
+			return nil
+		},
+	)
+	fs.BoolVar(&opts.events, "events", true, "Display the events of the spans")
+	fs.IntVar(&opts.width, "width", 40, "The width of the timeline")
+	_ = fs.Parse(args)
+
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if opts.width < 1 {
+		return fmt.Errorf("invalid width %d", opts.width)
+	}
+	if attrs != "" {
+		opts.attrs = strings.Split(attrs, ",")
+	}
+
+	spans, err := src.trace(context.Background(), fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	return renderWaterfall(os.Stdout, spans, opts)
+}
+
+func diff(args []string) error {
+	var src source
+
+	fs := newFlagSet("diff", "<trace id> <trace id>")
+	src.register(fs)
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	a, err := src.trace(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := src.trace(ctx, fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	return renderDiff(os.Stdout, a, b)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/username/otel-playground/internal/lib/environment"
+	"github.com/username/otel-playground/internal/lib/otlpjson"
+	"github.com/username/otel-playground/internal/lib/web"
+)
+
+// traceQuery selects the traces listed, the zero values match everything
+type traceQuery struct {
+	service string
+	name    string
+	start   time.Time
+	limit   int
+}
+
+// source reads the traces from the files when there are some, from the otlpsink otherwise
+type source struct {
+	sink  string
+	files []string
+}
+
+func (s *source) register(fs *flag.FlagSet) {
+	fs.StringVar(&s.sink, "sink", environment.Get("OTLP_SINK_URL", "http://localhost:4318"), "The url of the otlpsink")
+	fs.Func(
+		"file", "A file of OTLP/JSON lines read instead of the otlpsink, can be repeated",
+		func(name string) error {
+			s.files = append(s.files, name)
+			return nil
+		},
+	)
+}
+
+// trace returns the spans of the trace, or of the last one when id is empty
+func (s *source) trace(ctx context.Context, id string) ([]otlpjson.Span, error) {
+	if len(s.files) > 0 {
+		return s.traceFromFiles(id)
+	}
+
+	if id == "" {
+		traces, err := s.traces(ctx, traceQuery{limit: 1})
+		if err != nil {
+			return nil, err
+		}
+		if len(traces) == 0 {
+			return nil, errors.New("no trace in the otlpsink")
+		}
+		id = traces[0].TraceID
+	}
+
+	resp, err := web.Get[struct {
+		Spans []otlpjson.Span `json:"spans"`
+	}](ctx, s.client(), "/api/traces/"+id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the trace %s: %w", id, err)
+	}
+
+	return resp.Spans, nil
+}
+
+// traces returns the last traces matching the query, from the oldest to the newest
+func (s *source) traces(ctx context.Context, q traceQuery) ([]otlpjson.TraceSummary, error) {
+	if len(s.files) > 0 {
+		return s.tracesFromFiles(q)
+	}
+
+	query := web.NewQuery().Set("limit", q.limit)
+	if q.service != "" {
+		query.Set("service", q.service)
+	}
+	if q.name != "" {
+		query.Set("name", q.name)
+	}
+	if !q.start.IsZero() {
+		query.Set("start", q.start.Format(time.RFC3339))
+	}
+
+	resp, err := web.Get[struct {
+		Traces []otlpjson.TraceSummary `json:"traces"`
+	}](ctx, s.client(), "/api/traces", web.WithQuery(query))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the traces: %w", err)
+	}
+
+	return resp.Traces, nil
+}
+
+func (s *source) client() *web.Client {
+	return web.NewClient(web.WithBaseURL(s.sink), web.WithTimeout(10*time.Second))
+}
+
+func (s *source) traceFromFiles(id string) ([]otlpjson.Span, error) {
+	traces, err := s.readFiles()
+	if err != nil {
+		return nil, err
+	}
+	if len(traces) == 0 {
+		return nil, errors.New("no trace in the files")
+	}
+
+	if id == "" {
+		return traces[len(traces)-1], nil
+	}
+	for _, spans := range traces {
+		if spans[0].TraceID == id {
+			return spans, nil
+		}
+	}
+
+	return nil, fmt.Errorf("trace %s not found", id)
+}
+
+func (s *source) tracesFromFiles(q traceQuery) ([]otlpjson.TraceSummary, error) {
+	traces, err := s.readFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []otlpjson.TraceSummary
+	for _, spans := range traces {
+		if q.matches(spans) {
+			summaries = append(summaries, otlpjson.Summarize(spans))
+		}
+	}
+
+	if q.limit > 0 && len(summaries) > q.limit {
+		summaries = summaries[len(summaries)-q.limit:]
+	}
+
+	return summaries, nil
+}
+
+func (q traceQuery) matches(spans []otlpjson.Span) bool {
+	for _, span := range spans {
+		if (q.service == "" || q.service == span.Service) &&
+			(q.name == "" || q.name == span.Name) &&
+			(q.start.IsZero() || !span.End.Before(q.start)) {
+			return true
+		}
+	}
+	return false
+}
+
+// readFiles reads the spans of the files grouped by trace, the traces are sorted by start
+func (s *source) readFiles() ([][]otlpjson.Span, error) {
+	spans, err := otlpjson.ReadFiles(s.files...)
+	if err != nil {
+		return nil, err
+	}
+
+	byTrace := map[string][]otlpjson.Span{}
+	for _, span := range spans {
+		byTrace[span.TraceID] = append(byTrace[span.TraceID], span)
+	}
+
+	traces := make([][]otlpjson.Span, 0, len(byTrace))
+	for _, spans := range byTrace {
+		sort.SliceStable(spans, func(i, j int) bool { return spans[i].Start.Before(spans[j].Start) })
+		traces = append(traces, spans)
+	}
+	sort.Slice(traces, func(i, j int) bool { return traces[i][0].Start.Before(traces[j][0].Start) })
+
+	return traces, nil
+}
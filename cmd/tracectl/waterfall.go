@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/username/otel-playground/internal/lib/otlpjson"
+)
+
+// node is a span of the trace tree
+type node struct {
+	span     otlpjson.Span
+	depth    int
+	children []*node
+}
+
+// buildTree links the spans to their parent, the spans whose parent is missing are roots.
+// The roots and the children are sorted by start.
+func buildTree(spans []otlpjson.Span) []*node {
+	nodes := make(map[string]*node, len(spans))
+	for _, span := range spans {
+		nodes[span.SpanID] = &node{span: span}
+	}
+
+	var roots []*node
+	for _, span := range spans {
+		n := nodes[span.SpanID]
+		if parent, ok := nodes[span.ParentSpanID]; ok && parent != n {
+			parent.children = append(parent.children, n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+
+	byStart := func(nodes []*node) {
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].span.Start.Before(nodes[j].span.Start) })
+	}
+	byStart(roots)
+	walk(
+		roots, func(n *node) {
+			byStart(n.children)
+			for _, child := range n.children {
+				child.depth = n.depth + 1
+			}
+		},
+	)
+
+	return roots
+}
+
+// walk calls fn on the nodes depth first, the parents before their children
+func walk(nodes []*node, fn func(*node)) {
+	for _, n := range nodes {
+		fn(n)
+		walk(n.children, fn)
+	}
+}
+
+// matcher selects the spans by attribute, any value matches when value is empty
+type matcher struct {
+	key, value string
+}
+
+func parseMatcher(s string) matcher {
+	key, value, _ := strings.Cut(s, "=")
+	return matcher{key: key, value: value}
+}
+
+func (m matcher) match(span otlpjson.Span) bool {
+	value, ok := span.Attributes[m.key]
+	return ok && (m.value == "" || m.value == value)
+}
+
+type waterfallOptions struct {
+	// attrs are the attributes displayed after the spans, * displays all of them
+	attrs []string
+	// where keeps the spans matching all the matchers, with their parents
+	where  []matcher
+	events bool
+	width  int
+}
+
+// visible returns the spans kept by the matchers, nil when every span is kept
+func (o waterfallOptions) visible(roots []*node) map[*node]bool {
+	if len(o.where) == 0 {
+		return nil
+	}
+
+	kept := map[*node]bool{}
+	var visit func(n *node) bool
+	visit = func(n *node) bool {
+		keep := true
+		for _, m := range o.where {
+			keep = keep && m.match(n.span)
+		}
+		for _, child := range n.children {
+			// the children are all visited to keep each of the matching ones
+			keep = visit(child) || keep
+		}
+		kept[n] = keep
+		return keep
+	}
+	for _, root := range roots {
+		visit(root)
+	}
+
+	return kept
+}
+
+func (o waterfallOptions) attributes(span otlpjson.Span) string {
+	keys := o.attrs
+	if len(keys) == 1 && keys[0] == "*" {
+		keys = make([]string, 0, len(span.Attributes))
+		for key := range span.Attributes {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	}
+
+	var parts []string
+	for _, key := range keys {
+		if value, ok := span.Attributes[key]; ok {
+			parts = append(parts, fmt.Sprintf("%s=%q", key, truncate(value)))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// line is a row of the waterfall, a span or one of its events
+type line struct {
+	label, service, duration, status, timeline, attributes string
+}
+
+// renderWaterfall writes the spans as a tree, each span with its timeline relative to the whole trace
+func renderWaterfall(w io.Writer, spans []otlpjson.Span, opts waterfallOptions) error {
+	if len(spans) == 0 {
+		return fmt.Errorf("no span to render")
+	}
+
+	roots := buildTree(spans)
+	start, end := bounds(spans)
+	visible := opts.visible(roots)
+
+	var lines []line
+	failed := 0
+	walk(
+		roots, func(n *node) {
+			if n.span.Failed {
+				failed++
+			}
+			if visible != nil && !visible[n] {
+				return
+			}
+
+			indent := strings.Repeat("  ", n.depth)
+			status := ""
+			if n.span.Failed {
+				status = "ERROR"
+				if n.span.StatusMessage != "" {
+					status += " " + n.span.StatusMessage
+				}
+			}
+
+			lines = append(
+				lines, line{
+					label:      indent + n.span.Name,
+					service:    n.span.Service,
+					duration:   formatDuration(n.span.Duration()),
+					status:     status,
+					timeline:   timeline(opts.width, start, end, n.span.Start, n.span.End),
+					attributes: opts.attributes(n.span),
+				},
+			)
+
+			if !opts.events {
+				return
+			}
+			for _, event := range n.span.Events {
+				lines = append(
+					lines, line{
+						label:      indent + "  · " + event.Name,
+						duration:   "+" + formatDuration(event.Time.Sub(n.span.Start)),
+						timeline:   timeline(opts.width, start, end, event.Time, event.Time),
+						attributes: eventAttributes(event),
+					},
+				)
+			}
+		},
+	)
+
+	if _, err := fmt.Fprintf(
+		w, "trace %s  %d spans  %s  %d errors\n\n", spans[0].TraceID, len(spans), formatDuration(end.Sub(start)), failed,
+	); err != nil {
+		return err
+	}
+
+	return writeLines(w, lines)
+}
+
+func writeLines(w io.Writer, lines []line) error {
+	var labelWidth, serviceWidth, durationWidth int
+	for _, l := range lines {
+		labelWidth = max(labelWidth, len([]rune(l.label)))
+		serviceWidth = max(serviceWidth, len(l.service))
+		durationWidth = max(durationWidth, len([]rune(l.duration)))
+	}
+
+	for _, l := range lines {
+		row := fmt.Sprintf(
+			"%s  %-*s  %s  %s",
+			pad(l.label, labelWidth), serviceWidth, l.service, padLeft(l.duration, durationWidth), l.timeline,
+		)
+		for _, s := range []string{l.status, l.attributes} {
+			if s != "" {
+				row += "  " + s
+			}
+		}
+
+		if _, err := fmt.Fprintln(w, strings.TrimRight(row, " ")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pad right-pads the string to width runes, fmt pads to a number of bytes, e.g. of µs
+func pad(s string, width int) string {
+	return s + strings.Repeat(" ", width-len([]rune(s)))
+}
+
+// padLeft left-pads the string to width runes
+func padLeft(s string, width int) string {
+	return strings.Repeat(" ", width-len([]rune(s))) + s
+}
+
+// timeline draws the period from start to end within the trace, a point when they are equal
+func timeline(width int, traceStart, traceEnd, start, end time.Time) string {
+	total := traceEnd.Sub(traceStart)
+	position := func(t time.Time) int {
+		if total <= 0 {
+			return 0
+		}
+		return min(int(float64(width)*float64(t.Sub(traceStart))/float64(total)), width-1)
+	}
+
+	from, to := position(start), position(end)
+	cells := []rune(strings.Repeat(" ", width))
+	if start.Equal(end) {
+		cells[from] = '◆'
+	} else {
+		for i := from; i <= to; i++ {
+			cells[i] = '█'
+		}
+	}
+
+	return "|" + string(cells) + "|"
+}
+
+// eventAttributes formats the attributes of the event, without the stack traces of the exceptions
+func eventAttributes(event otlpjson.SpanEvent) string {
+	keys := make([]string, 0, len(event.Attributes))
+	for key := range event.Attributes {
+		if key != "exception.stacktrace" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%q", key, truncate(event.Attributes[key])))
+	}
+	return strings.Join(parts, " ")
+}
+
+// maxValueLength bounds the attribute values displayed, e.g. the error messages with a response body
+const maxValueLength = 80
+
+func truncate(value string) string {
+	if runes := []rune(value); len(runes) > maxValueLength {
+		return string(runes[:maxValueLength]) + "…"
+	}
+	return value
+}
+
+// bounds returns the start of the first span and the end of the last one
+func bounds(spans []otlpjson.Span) (start, end time.Time) {
+	start, end = spans[0].Start, spans[0].End
+	for _, span := range spans {
+		if span.Start.Before(start) {
+			start = span.Start
+		}
+		if span.End.After(end) {
+			end = span.End
+		}
+	}
+	return start, end
+}
+
+// formatDuration rounds the duration to 3 or 4 significant digits
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	case d >= time.Microsecond:
+		return d.Round(time.Microsecond).String()
+	default:
+		return d.String()
+	}
+}
@@ -2,8 +2,8 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
-	"fmt"
 	"log"
 	"math/rand"
 	"net/http"
@@ -13,7 +13,10 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 
+	"github.com/username/otel-playground/internal/lib/certs"
+	"github.com/username/otel-playground/internal/lib/chars"
 	"github.com/username/otel-playground/internal/lib/collections"
+	"github.com/username/otel-playground/internal/lib/environment"
 	libmath "github.com/username/otel-playground/internal/lib/math"
 	"github.com/username/otel-playground/internal/lib/random"
 	"github.com/username/otel-playground/internal/lib/telemetry"
@@ -27,6 +30,8 @@ const (
 
 var tracer trace.Tracer
 
+var charsServed *chars.Counter
+
 var letters = []rune{
 	'A',
 	'B',
@@ -61,32 +66,67 @@ func init() {
 }
 
 func main() {
-	var port int
+	var port, adminPort int
 	flag.IntVar(&port, "port", 5000, "The port to listen on")
+	flag.IntVar(&adminPort, "admin-port", 0, "The port to serve pprof and runtime introspection on, 0 disables it")
 	flag.Parse()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	tlsConfig := certs.FromEnv()
+	serverTLS, err := tlsConfig.ServerTLS()
+	if err != nil {
+		log.Fatalf("failed to load server certificates: %v\n", err)
+	}
+	clientTLS, err := tlsConfig.ClientTLS()
+	if err != nil {
+		log.Fatalf("failed to load client certificates: %v\n", err)
+	}
+
+	baggagePolicy := web.BaggagePolicyFromEnv()
+	redactor := chars.Redactor()
+
 	client, err := telemetry.Configure(
 		ctx,
 		telemetry.WithServiceName(serviceName),
 		telemetry.WithServiceVersion(serviceVersion),
+		telemetry.WithExporterTLS(clientTLS),
+		telemetry.WithProfiling(telemetry.ProfilingConfigFromEnv()),
+		telemetry.WithTailSampling(telemetry.TailSamplingConfigFromEnv()),
+		telemetry.WithExporter(telemetry.SignalTraces, telemetry.ExportersFromEnv(telemetry.SignalTraces)...),
+		telemetry.WithExporter(telemetry.SignalMetrics, telemetry.ExportersFromEnv(telemetry.SignalMetrics)...),
+		telemetry.WithPrometheusEndpoint(environment.Get("PROMETHEUS_ENDPOINT", "")),
+		telemetry.WithStrict(environment.Get("TELEMETRY_STRICT", false)),
+		telemetry.WithSpanBaggage(baggagePolicy.Promote...),
+		telemetry.WithRedactor(redactor),
 	)
 	if err != nil {
 		log.Fatalf("failed to register tracer: %v\n", err)
 	}
-	defer func() {
-		client.Shutdown(context.Background())
-	}()
 
 	tracer = otel.Tracer("main")
 
-	mux := http.NewServeMux()
-	web.Handler(mux, "/", http.HandlerFunc(upperHandler))
-	web.HealthCheckHandler(mux, serviceName, serviceVersion)
+	if charsServed, err = chars.NewCounter(otel.Meter("main"), redactor); err != nil {
+		log.Fatalf("failed to create metrics: %v\n", err)
+	}
 
-	if err := web.Server(port, mux, serviceName, web.FilterURLs{"/healthcheck"}); err != nil {
+	router := web.NewRouter()
+	router.Get("/", upperHandler)
+	web.HealthCheckHandler(router, serviceName, serviceVersion)
+
+	srv := web.NewServer(
+		router,
+		web.WithPort(port),
+		web.WithAdminPort(adminPort),
+		web.WithName(serviceName),
+		web.WithFilters(web.FilterURLs{"/healthcheck"}),
+		web.WithTLS(serverTLS),
+		web.WithTelemetry(client),
+		web.WithBaggagePolicy(baggagePolicy),
+		web.WithResponseLogging(environment.Get("LOG_RESPONSES", false), redactor),
+	)
+	if err := srv.Run(); err != nil {
 		log.Fatalf("failed to start server: %v\n", err)
 	}
 }
@@ -96,9 +136,10 @@ func upperHandler(w http.ResponseWriter, r *http.Request) {
 
 	char, err := processUpper(r.Context(), char)
 	if err != nil {
-		web.ServerErrorResponse(w, err)
+		web.ErrorResponse(w, r, err)
 		return
 	}
+	charsServed.Add(r.Context(), char)
 
 	web.WriteJSON(w, http.StatusOK, renderUpper(r.Context(), char))
 }
@@ -110,31 +151,36 @@ func processUpper(ctx context.Context, char rune) (rune, error) {
 	defer span.End()
 
 	span.AddEvent("processing_upper_char", withAttr)
-	work(0.0001, 0.00005)
+	work(spctx, 0.0001, 0.00005)
 
 	// 1/100 calls is extra slow
 	if rand.Float64() > 0.99 {
 		span.AddEvent("extra_work", withAttr)
-		work(0.0002, 0.0001)
+		work(spctx, 0.0002, 0.0001)
 	}
 
 	// these chars are extra slow
 	if collections.SliceContains(char, []rune{'Z', 'X', 'R'}) {
-		if _, span := tracer.Start(ctx, "extra_process_upper", withAttr); span != nil {
-			work(0.005, 0.0005)
+		if ctx, span := tracer.Start(ctx, "extra_process_upper", withAttr); span != nil {
+			work(ctx, 0.005, 0.0005)
 			span.End()
 		}
 	}
 
 	// these chars are extra slow and sometimes fail
 	if collections.SliceContains(char, []rune{'Z', 'A', 'T'}) {
-		if _, span := tracer.Start(spctx, "extra_extra_process_upper", withAttr); span != nil {
+		if ctx, span := tracer.Start(spctx, "extra_extra_process_upper", withAttr); span != nil {
 			defer span.End()
-			work(0.0001, 0.00008)
+			work(ctx, 0.0001, 0.00008)
 
 			// fails 5% of the time
 			if rand.Float64() > 0.95 {
-				err := fmt.Errorf("failed to process '%c'", char)
+				err := web.NewRetryableError(
+					http.StatusServiceUnavailable,
+					web.CodeUnavailable,
+					"the char could not be processed",
+					errors.New("failed to process the char"),
+				)
 				telemetry.RecordError(spctx, err)
 				return -1, err
 			}
@@ -145,11 +191,11 @@ func processUpper(ctx context.Context, char rune) (rune, error) {
 }
 
 func randomUpper(ctx context.Context) rune {
-	_, span := tracer.Start(ctx, "random_upper", trace.WithSpanKind(trace.SpanKindInternal))
+	ctx, span := tracer.Start(ctx, "random_upper", trace.WithSpanKind(trace.SpanKindInternal))
 	defer span.End()
 
 	// gets progressively slower throughout the hour
-	work(float64(time.Now().Minute()/10000.0), 0.00001)
+	work(ctx, float64(time.Now().Minute()/10000.0), 0.00001)
 
 	char := random.Choice(letters)
 	span.SetAttributes(attribute.String("char", string(char)))
@@ -158,14 +204,18 @@ func randomUpper(ctx context.Context) rune {
 }
 
 func renderUpper(ctx context.Context, char rune) interface{} {
-	_, span := tracer.Start(ctx, "render_upper", trace.WithSpanKind(trace.SpanKindInternal))
+	ctx, span := tracer.Start(ctx, "render_upper", trace.WithSpanKind(trace.SpanKindInternal))
 	defer span.End()
 
-	work(0.0002, 0.0001)
+	work(ctx, 0.0002, 0.0001)
 
 	return web.Envelope{"char": string(char)}
 }
 
-func work(mean, sigma float64) {
-	time.Sleep(time.Duration(libmath.Max(0.0, random.Normalvariate(mean, sigma))))
+func work(ctx context.Context, mean, sigma float64) {
+	telemetry.Do(
+		ctx, func(context.Context) {
+			time.Sleep(time.Duration(libmath.Max(0.0, random.Normalvariate(mean, sigma))))
+		},
+	)
 }
@@ -0,0 +1,142 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/username/otel-playground/internal/lib/environment"
+)
+
+// Config describes the certificates used by a service, both as server and as client.
+type Config struct {
+	// CertFile and KeyFile are the service key pair, presented to clients and, for mTLS, to servers
+	CertFile string
+	KeyFile  string
+	// CAFile verifies the servers the service connects to
+	CAFile string
+	// ClientCAFile requires and verifies the client certificates
+	ClientCAFile string
+	// MinVersion is the lowest TLS version accepted, 1.2 or 1.3, 1.2 when empty
+	MinVersion string
+	// ReloadInterval is how often the files are checked for changes
+	ReloadInterval time.Duration
+}
+
+// FromEnv reads the configuration from the TLS_* environment variables.
+func FromEnv() Config {
+	return Config{
+		CertFile:       environment.Get("TLS_CERT_FILE", ""),
+		KeyFile:        environment.Get("TLS_KEY_FILE", ""),
+		CAFile:         environment.Get("TLS_CA_FILE", ""),
+		ClientCAFile:   environment.Get("TLS_CLIENT_CA_FILE", ""),
+		MinVersion:     environment.Get("TLS_MIN_VERSION", "1.2"),
+		ReloadInterval: time.Duration(environment.Get("TLS_RELOAD_INTERVAL_SECONDS", 10)) * time.Second,
+	}
+}
+
+// minVersion parses the minimal version, the versions below 1.2 are refused
+func (c Config) minVersion() (uint16, error) {
+	switch c.MinVersion {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	case "1.0", "1.1":
+		return 0, fmt.Errorf("TLS %s is no longer secure, the minimal version must be 1.2 or 1.3", c.MinVersion)
+	default:
+		return 0, fmt.Errorf("unknown TLS version '%s'", c.MinVersion)
+	}
+}
+
+// ServerTLS returns the server configuration, or nil when no certificate is configured.
+// Client certificates are required when a client CA is set.
+func (c Config) ServerTLS() (*tls.Config, error) {
+	if c.CertFile == "" {
+		return nil, nil
+	}
+
+	minVersion, err := c.minVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	reloader, err := newReloader(c.CertFile, c.KeyFile, c.ClientCAFile, c.ReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion: minVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, pool, err := reloader.current()
+			if err != nil {
+				return nil, err
+			}
+
+			cfg := &tls.Config{
+				MinVersion:   minVersion,
+				Certificates: []tls.Certificate{*cert},
+			}
+			if pool != nil {
+				cfg.ClientCAs = pool
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+
+			return cfg, nil
+		},
+	}, nil
+}
+
+// ClientTLS returns the client configuration, or nil when neither a CA nor a certificate is configured.
+// The certificate is only sent when the server asks for it.
+func (c Config) ClientTLS() (*tls.Config, error) {
+	if c.CAFile == "" && c.CertFile == "" {
+		return nil, nil
+	}
+
+	minVersion, err := c.minVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{MinVersion: minVersion}
+
+	if c.CAFile != "" {
+		pool, err := loadPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+
+	if c.CertFile != "" {
+		reloader, err := newReloader(c.CertFile, c.KeyFile, "", c.ReloadInterval)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _, err := reloader.current()
+			return cert, err
+		}
+	}
+
+	return cfg, nil
+}
+
+func loadPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ca file '%s': %w", file, err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificate found in ca file '%s'", file)
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,111 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// reloader keeps the key pair and the ca pool in memory, reloading them when the files change.
+// The files are checked at most once per interval, during the handshakes.
+type reloader struct {
+	certFile, keyFile, caFile string
+	interval                  time.Duration
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func newReloader(certFile, keyFile, caFile string, interval time.Duration) (*reloader, error) {
+	r := &reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		interval: interval,
+	}
+
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *reloader) current() (*tls.Certificate, *x509.CertPool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if time.Since(r.checkedAt) < r.interval {
+		return r.cert, r.pool, nil
+	}
+	r.checkedAt = time.Now()
+
+	modTime, err := r.lastModified()
+	if err != nil || !modTime.After(r.modTime) {
+		return r.cert, r.pool, nil
+	}
+
+	// keep serving the previous certificate when the new files are not valid yet, e.g. half written
+	if err := r.load(); err != nil {
+		log.Printf("failed to reload certificates: %v\n", err)
+		return r.cert, r.pool, nil
+	}
+
+	log.Printf("reloaded certificate '%s'\n", r.certFile)
+
+	return r.cert, r.pool, nil
+}
+
+func (r *reloader) load() error {
+	modTime, err := r.lastModified()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load key pair '%s': %w", r.certFile, err)
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		if pool, err = loadPool(r.caFile); err != nil {
+			return err
+		}
+	}
+
+	r.cert = &cert
+	r.pool = pool
+	r.modTime = modTime
+	r.checkedAt = time.Now()
+
+	return nil
+}
+
+func (r *reloader) lastModified() (time.Time, error) {
+	var last time.Time
+
+	for _, file := range []string{r.certFile, r.keyFile, r.caFile} {
+		if file == "" {
+			continue
+		}
+
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to stat '%s': %w", file, err)
+		}
+
+		if info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+	}
+
+	return last, nil
+}
@@ -0,0 +1,47 @@
+package chars
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/username/otel-playground/internal/lib/redact"
+)
+
+// Redactor hashes the "char" attributes of the spans, logs, responses and of chars.served: the chars
+// of a password can't be read from its trace, nor from the exemplars linking the served chars to it.
+func Redactor() *redact.Redactor {
+	return redact.FromEnv(redact.Rule{Pattern: "char", Action: redact.Hash})
+}
+
+// Counter counts the chars served by the char services per value, the distribution of the served chars
+// shows the drift caused by the slow and failing ones.
+type Counter struct {
+	served   metric.Int64Counter
+	redactor *redact.Redactor
+}
+
+// NewCounter returns a counter whose char attribute is redacted, a nil redactor records it in clear
+func NewCounter(meter metric.Meter, redactor *redact.Redactor) (*Counter, error) {
+	served, err := meter.Int64Counter(
+		"chars.served",
+		metric.WithDescription("characters served per value"),
+		metric.WithUnit("{char}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Counter{served: served, redactor: redactor}, nil
+}
+
+// Add counts a served char
+func (c *Counter) Add(ctx context.Context, char rune) {
+	value, ok := c.redactor.Value("char", string(char))
+	if !ok {
+		c.served.Add(ctx, 1)
+		return
+	}
+	c.served.Add(ctx, 1, metric.WithAttributes(attribute.String("char", value)))
+}
@@ -31,8 +31,9 @@ func Get[T any](key string, fallback T) T {
 		ret = int(i)
 
 	case bool:
-		b, _ := regexp.Match(`(?i)(false|0)`, []byte(value))
-		ret = b
+		// anything but "false" or "0" enables the flag
+		disabled, _ := regexp.Match(`(?i)^(false|0)$`, []byte(value))
+		ret = !disabled
 	}
 
 	return ret.(T)
@@ -0,0 +1,271 @@
+package otlpjson
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// TracesData is the OTLP/JSON encoding of the spans, as written line by line by the collector file exporter.
+// The ids are hex encoded and the 64 bits integers are strings.
+type TracesData struct {
+	ResourceSpans []ResourceSpans `json:"resourceSpans"`
+}
+
+type ResourceSpans struct {
+	Resource   Resource     `json:"resource"`
+	ScopeSpans []ScopeSpans `json:"scopeSpans"`
+	// InstrumentationLibrarySpans is the name of ScopeSpans before OTLP 0.15
+	InstrumentationLibrarySpans []ScopeSpans `json:"instrumentationLibrarySpans,omitempty"`
+	SchemaURL                   string       `json:"schemaUrl,omitempty"`
+}
+
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+type ScopeSpans struct {
+	Scope Scope      `json:"scope"`
+	Spans []SpanData `json:"spans"`
+}
+
+type Scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type SpanData struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []KeyValue `json:"attributes,omitempty"`
+	Events            []Event    `json:"events,omitempty"`
+	Status            Status     `json:"status"`
+}
+
+type Event struct {
+	TimeUnixNano string     `json:"timeUnixNano"`
+	Name         string     `json:"name"`
+	Attributes   []KeyValue `json:"attributes,omitempty"`
+}
+
+type Status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+type AnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// String returns the value whatever its type, the arrays and maps are left out
+func (v AnyValue) String() string {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return strconv.FormatBool(*v.BoolValue)
+	case v.IntValue != nil:
+		return *v.IntValue
+	case v.DoubleValue != nil:
+		return strconv.FormatFloat(*v.DoubleValue, 'g', -1, 64)
+	default:
+		return ""
+	}
+}
+
+// The span kinds and status codes of OTLP
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+	KindProducer = 4
+	KindConsumer = 5
+
+	StatusOk    = 1
+	StatusError = 2
+)
+
+var kindNames = map[int]string{
+	KindInternal: "internal",
+	KindServer:   "server",
+	KindClient:   "client",
+	KindProducer: "producer",
+	KindConsumer: "consumer",
+}
+
+// Span is a span flattened with the attributes of its resource, easier to pair and query than TracesData.
+type Span struct {
+	TraceID       string            `json:"trace_id"`
+	SpanID        string            `json:"span_id"`
+	ParentSpanID  string            `json:"parent_span_id,omitempty"`
+	Service       string            `json:"service"`
+	Name          string            `json:"name"`
+	Kind          string            `json:"kind"`
+	Start         time.Time         `json:"start"`
+	End           time.Time         `json:"end"`
+	Failed        bool              `json:"failed"`
+	StatusMessage string            `json:"status_message,omitempty"`
+	Attributes    map[string]string `json:"attributes,omitempty"`
+	Events        []SpanEvent       `json:"events,omitempty"`
+}
+
+// SpanEvent is an event of a flattened span
+type SpanEvent struct {
+	Time       time.Time         `json:"time"`
+	Name       string            `json:"name"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Duration returns how long the span lasted
+func (s Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// Point is a metric data point flattened with its metric and the service of its resource
+type Point struct {
+	Time       time.Time         `json:"time"`
+	Service    string            `json:"service"`
+	Name       string            `json:"name"`
+	Unit       string            `json:"unit,omitempty"`
+	Type       string            `json:"type"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// Value is the value of the gauges and sums
+	Value float64 `json:"value"`
+	// Count and Sum are the values of the histograms
+	Count uint64  `json:"count,omitempty"`
+	Sum   float64 `json:"sum,omitempty"`
+	// Bounds are the upper bounds of the explicit histogram buckets and BucketCounts the counts of the buckets,
+	// the last one counts the values above the last bound
+	Bounds       []float64 `json:"bounds,omitempty"`
+	BucketCounts []uint64  `json:"bucket_counts,omitempty"`
+}
+
+// Spans flattens the spans of the traces data
+func (d TracesData) Spans() []Span {
+	var spans []Span
+	for _, rs := range d.ResourceSpans {
+		resource := attributes(rs.Resource.Attributes)
+
+		for _, ss := range append(rs.ScopeSpans, rs.InstrumentationLibrarySpans...) {
+			for _, s := range ss.Spans {
+				var events []SpanEvent
+				for _, e := range s.Events {
+					events = append(
+						events, SpanEvent{Time: unixNano(e.TimeUnixNano), Name: e.Name, Attributes: attributes(e.Attributes)},
+					)
+				}
+
+				spans = append(
+					spans, Span{
+						TraceID:       s.TraceID,
+						SpanID:        s.SpanID,
+						ParentSpanID:  s.ParentSpanID,
+						Service:       resource["service.name"],
+						Name:          s.Name,
+						Kind:          kindNames[s.Kind],
+						Start:         unixNano(s.StartTimeUnixNano),
+						End:           unixNano(s.EndTimeUnixNano),
+						Failed:        s.Status.Code == StatusError,
+						StatusMessage: s.Status.Message,
+						Attributes:    attributes(s.Attributes),
+						Events:        events,
+					},
+				)
+			}
+		}
+	}
+
+	return spans
+}
+
+func attributes(kvs []KeyValue) map[string]string {
+	if len(kvs) == 0 {
+		return nil
+	}
+
+	attrs := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		attrs[kv.Key] = kv.Value.String()
+	}
+	return attrs
+}
+
+func unixNano(value string) time.Time {
+	nanos, _ := strconv.ParseInt(value, 10, 64)
+	return time.Unix(0, nanos)
+}
+
+// maxLineSize bounds the size of a line, a batch of spans
+const maxLineSize = 16 << 20
+
+// ReadSpans reads the spans of the TracesData written one per line, the empty lines are skipped.
+func ReadSpans(r io.Reader) ([]Span, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	var spans []Span
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var data TracesData
+		if err := json.Unmarshal(scanner.Bytes(), &data); err != nil {
+			return nil, fmt.Errorf("failed to decode line %d: %w", line, err)
+		}
+		spans = append(spans, data.Spans()...)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the spans: %w", err)
+	}
+
+	return spans, nil
+}
+
+// ReadFile reads the spans of a file of TracesData lines
+func ReadFile(name string) ([]Span, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	spans, err := ReadSpans(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return spans, nil
+}
+
+// ReadFiles reads the spans of the files, one after the other
+func ReadFiles(names ...string) ([]Span, error) {
+	var spans []Span
+	for _, name := range names {
+		s, err := ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		spans = append(spans, s...)
+	}
+
+	return spans, nil
+}
@@ -0,0 +1,53 @@
+package otlpjson
+
+import "time"
+
+// TraceSummary describes a trace in the listings of the otlpsink and tracectl
+type TraceSummary struct {
+	TraceID  string    `json:"trace_id"`
+	Service  string    `json:"service"`
+	Name     string    `json:"name"`
+	Start    time.Time `json:"start"`
+	Duration string    `json:"duration"`
+	Spans    int       `json:"spans"`
+	Failed   bool      `json:"failed"`
+}
+
+// Summarize describes the trace of the spans by its first root span, a span whose parent wasn't received.
+// The first span stands for the root when there is none, e.g. when the parent links form a cycle.
+// There must be at least one span.
+func Summarize(spans []Span) TraceSummary {
+	ids := make(map[string]bool, len(spans))
+	for _, span := range spans {
+		ids[span.SpanID] = true
+	}
+
+	root, rootFound := spans[0], false
+	first, end := spans[0], spans[0].End
+	failed := false
+	for _, span := range spans {
+		if !ids[span.ParentSpanID] && (!rootFound || span.Start.Before(root.Start)) {
+			root, rootFound = span, true
+		}
+		if span.Start.Before(first.Start) {
+			first = span
+		}
+		if span.End.After(end) {
+			end = span.End
+		}
+		failed = failed || span.Failed
+	}
+	if !rootFound {
+		root = first
+	}
+
+	return TraceSummary{
+		TraceID:  root.TraceID,
+		Service:  root.Service,
+		Name:     root.Name,
+		Start:    first.Start,
+		Duration: end.Sub(first.Start).String(),
+		Spans:    len(spans),
+		Failed:   failed,
+	}
+}
@@ -0,0 +1,117 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// JSON redacts the values of the keys matching the rules in a json document, at any depth.
+// A matching object or array is redacted as a whole, from its compact encoding, and the redacted values
+// are all strings. The keys keep their order.
+func (r *Redactor) JSON(data []byte) ([]byte, error) {
+	if r == nil {
+		return data, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var out bytes.Buffer
+	if err := r.redactJSONValue(dec, &out); err != nil {
+		return nil, fmt.Errorf("failed to redact the json: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("failed to redact the json: unexpected data after the document")
+	}
+
+	return out.Bytes(), nil
+}
+
+// redactJSONValue copies the next value of dec to out, redacting the members of the objects
+func (r *Redactor) redactJSONValue(dec *json.Decoder, out *bytes.Buffer) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return writeJSON(out, token)
+	}
+
+	out.WriteRune(rune(delim))
+	for i := 0; dec.More(); i++ {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+
+		if delim == '[' {
+			if err := r.redactJSONValue(dec, out); err != nil {
+				return err
+			}
+			continue
+		}
+
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := token.(string)
+		if err := writeJSON(out, key); err != nil {
+			return err
+		}
+		out.WriteByte(':')
+
+		if _, ok := r.Match(key); !ok {
+			if err := r.redactJSONValue(dec, out); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		if err := writeJSON(out, r.String(key, rawValue(raw))); err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	if delim == '{' {
+		out.WriteByte('}')
+	} else {
+		out.WriteByte(']')
+	}
+
+	return nil
+}
+
+// rawValue is the text redacted for a json value, the unquoted strings and the compact encoding of the others
+func rawValue(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return string(raw)
+	}
+	return compact.String()
+}
+
+func writeJSON(out *bytes.Buffer, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	out.Write(data)
+	return nil
+}
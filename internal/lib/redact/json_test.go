@@ -0,0 +1,44 @@
+package redact
+
+import "testing"
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		name, body, want string
+	}{
+		{"unmatched", `{"user": "bob", "id": 42}`, `{"user":"bob","id":42}`},
+		{"drop string", `{"password":"secret","user":"bob"}`, `{"password":"[REDACTED]","user":"bob"}`},
+		{"drop object", `{"password":{"old":"a","new":"b"},"user":"bob"}`, `{"password":"[REDACTED]","user":"bob"}`},
+		{"drop nested key", `{"users":[{"name":"bob","password":"a"}]}`, `{"users":[{"name":"bob","password":"[REDACTED]"}]}`},
+		{"hash string", `{"token":"abc"}`, `{"token":"hmac:5031dfb5b067c1d6"}`},
+		{"hash number", `{"token":42}`, `{"token":"hmac:98c2d78e000f17f1"}`},
+		{"hash object", `{"token":{"a": 1, "b": [true, null]}}`, `{"token":"hmac:76db250690b3ad77"}`},
+		{"mask string with escapes", `{"card":"a\"bé"}`, `{"card":"****"}`},
+		{"mask array", `{"card":[1, 2]}`, `{"card":"*****"}`},
+		{"keys keep their order", `{"z":1,"card":"12","a":[null,false]}`, `{"z":1,"card":"**","a":[null,false]}`},
+		{"top level array", `[{"password":"a"},"password"]`, `[{"password":"[REDACTED]"},"password"]`},
+	}
+
+	r := newTestRedactor()
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				got, err := r.JSON([]byte(tt.body))
+				if err != nil {
+					t.Fatalf("JSON(%s) failed: %v", tt.body, err)
+				}
+				if string(got) != tt.want {
+					t.Errorf("JSON(%s) = %s, want %s", tt.body, got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestJSONInvalid(t *testing.T) {
+	for _, body := range []string{`{"password":"a"`, `{"a":1} {"b":2}`, `not json`} {
+		if got, err := newTestRedactor().JSON([]byte(body)); err == nil {
+			t.Errorf("JSON(%s) = %s, want an error", body, got)
+		}
+	}
+}
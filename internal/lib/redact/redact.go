@@ -0,0 +1,114 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/username/otel-playground/internal/lib/environment"
+)
+
+// Action is what is done to the values matching a rule
+type Action int
+
+const (
+	// Drop removes the attribute, the values written in logs are replaced with "[REDACTED]"
+	Drop Action = iota
+	// Hash replaces the value with its salted hash, equal values can still be grouped
+	Hash
+	// Mask replaces every character of the value with '*'
+	Mask
+)
+
+const (
+	RedactedValue = "[REDACTED]"
+
+	// ModeSafe applies the rules, ModeUnsafe exports and logs everything as is
+	ModeSafe   = "safe"
+	ModeUnsafe = "unsafe"
+)
+
+// Rule applies an action to the keys matching the pattern, a path.Match pattern such as "password" or "*.password".
+type Rule struct {
+	Pattern string
+	Action  Action
+}
+
+// Redactor applies the first rule matching a key to its value. A nil Redactor keeps every value.
+type Redactor struct {
+	rules []Rule
+	salt  []byte
+}
+
+// New returns a redactor hashing with the given salt, a random one when it is empty.
+func New(salt string, rules ...Rule) *Redactor {
+	r := &Redactor{rules: rules, salt: []byte(salt)}
+	if len(r.salt) == 0 {
+		r.salt = make([]byte, 32)
+		if _, err := rand.Read(r.salt); err != nil {
+			log.Printf("failed to generate the redaction salt: %v\n", err)
+		}
+	}
+
+	return r
+}
+
+// FromEnv returns a redactor applying the rules with the REDACTION_SALT salt,
+// or nil when REDACTION_MODE is "unsafe" to show what the telemetry leaks without it.
+func FromEnv(rules ...Rule) *Redactor {
+	if strings.EqualFold(environment.Get("REDACTION_MODE", ModeSafe), ModeUnsafe) {
+		return nil
+	}
+
+	return New(environment.Get("REDACTION_SALT", ""), rules...)
+}
+
+// Match returns the action of the first rule matching the key
+func (r *Redactor) Match(key string) (Action, bool) {
+	if r == nil {
+		return 0, false
+	}
+
+	for _, rule := range r.rules {
+		if matched, _ := path.Match(rule.Pattern, key); matched {
+			return rule.Action, true
+		}
+	}
+
+	return 0, false
+}
+
+// Value returns the redacted value of the key and false when it must be dropped.
+func (r *Redactor) Value(key, value string) (string, bool) {
+	action, ok := r.Match(key)
+	if !ok {
+		return value, true
+	}
+
+	return r.apply(action, value)
+}
+
+// String returns the redacted value, with the dropped values replaced by RedactedValue.
+func (r *Redactor) String(key, value string) string {
+	if redacted, ok := r.Value(key, value); ok {
+		return redacted
+	}
+	return RedactedValue
+}
+
+func (r *Redactor) apply(action Action, value string) (string, bool) {
+	switch action {
+	case Hash:
+		mac := hmac.New(sha256.New, r.salt)
+		mac.Write([]byte(value))
+		return "hmac:" + hex.EncodeToString(mac.Sum(nil)[:8]), true
+	case Mask:
+		return strings.Repeat("*", len([]rune(value))), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,98 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+)
+
+// keyValues matches the key='value', key=value and "key":"value" forms written in the logs, only the opening
+// bracket of a json object or array is matched, its end is found by decoding it
+var keyValues = regexp.MustCompile(`([A-Za-z_][\w.]*)=('[^']*'|"[^"]*"|[^\s,]*)|"([A-Za-z_][\w.]*)":\s*("(?:[^"\\]|\\.)*"|[{\[]|[^\s,}\]]*)`)
+
+// Writer redacts the values written as key=value or "key":"value", it is the hook to set with log.SetOutput.
+// The lines are expected to be written at once, as the loggers of the log package do.
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	if r == nil {
+		return w
+	}
+
+	return writer{redactor: r, next: w}
+}
+
+type writer struct {
+	redactor *Redactor
+	next     io.Writer
+}
+
+func (w writer) Write(p []byte) (int, error) {
+	if _, err := w.next.Write(w.redactor.Line(p)); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Line redacts the values of the keys matching the rules in a log line
+func (r *Redactor) Line(line []byte) []byte {
+	if r == nil {
+		return line
+	}
+
+	var out bytes.Buffer
+	for pos := 0; pos < len(line); {
+		loc := keyValues.FindSubmatchIndex(line[pos:])
+		if loc == nil {
+			out.Write(line[pos:])
+			break
+		}
+		for i := range loc {
+			if loc[i] >= 0 {
+				loc[i] += pos
+			}
+		}
+
+		out.Write(line[pos:loc[0]])
+		pos = loc[1]
+
+		if loc[2] >= 0 {
+			key, value := string(line[loc[2]:loc[3]]), line[loc[4]:loc[5]]
+			if _, ok := r.Match(key); !ok {
+				out.Write(line[loc[0]:loc[1]])
+				continue
+			}
+
+			quote := ""
+			if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') {
+				quote, value = string(value[0]), value[1:len(value)-1]
+			}
+			out.WriteString(key + "=" + quote + r.String(key, string(value)) + quote)
+			continue
+		}
+
+		key, value := string(line[loc[6]:loc[7]]), line[loc[8]:loc[9]]
+		if _, ok := r.Match(key); !ok {
+			out.Write(line[loc[0]:loc[1]])
+			continue
+		}
+
+		// an object or an array goes to its closing bracket, or to the end of a truncated line
+		if len(value) > 0 && (value[0] == '{' || value[0] == '[') {
+			dec := json.NewDecoder(bytes.NewReader(line[loc[8]:]))
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err == nil {
+				pos = loc[8] + int(dec.InputOffset())
+			} else {
+				pos = len(line)
+			}
+			value = line[loc[8]:pos]
+		}
+
+		// the redacted numbers, booleans and objects become strings, keeping the json valid
+		redacted, _ := json.Marshal(r.String(key, rawValue(value)))
+		out.WriteString(`"` + key + `":` + string(redacted))
+	}
+
+	return out.Bytes()
+}
@@ -0,0 +1,62 @@
+package redact
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func newTestRedactor() *Redactor {
+	return New(
+		"salt",
+		Rule{Pattern: "password", Action: Drop},
+		Rule{Pattern: "token", Action: Hash},
+		Rule{Pattern: "card", Action: Mask},
+	)
+}
+
+func TestLine(t *testing.T) {
+	tests := []struct {
+		name, line, want string
+	}{
+		{"unmatched", `user=bob "id":42`, `user=bob "id":42`},
+		{"drop plain", `user=bob password=secret done`, `user=bob password=[REDACTED] done`},
+		{"drop quoted", `password='my secret', user=bob`, `password='[REDACTED]', user=bob`},
+		{"hash plain", `token=abc`, `token=hmac:5031dfb5b067c1d6`},
+		{"mask double quoted", `card="1234 5678"`, `card="*********"`},
+		{"drop json string", `{"password":"secret","user":"bob"}`, `{"password":"[REDACTED]","user":"bob"}`},
+		{"hash json number", `{"token": 42}`, `{"token":"hmac:98c2d78e000f17f1"}`},
+		{"mask json escapes", `{"card":"a\"bé"}`, `{"card":"****"}`},
+		{"drop json object", `{"password":{"old":"a","new":"b"},"user":"bob"}`, `{"password":"[REDACTED]","user":"bob"}`},
+		{"hash json object", `{"token":{"a": 1, "b": [true, null]}}`, `{"token":"hmac:76db250690b3ad77"}`},
+		{"mask json array", `{"card":[1,2]}`, `{"card":"*****"}`},
+		{"drop truncated object", `{"password":{"old":"a",`, `{"password":"[REDACTED]"`},
+		{"json in a log line", `response {"password":["a","b"]} sent`, `response {"password":"[REDACTED]"} sent`},
+	}
+
+	r := newTestRedactor()
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				if got := string(r.Line([]byte(tt.line))); got != tt.want {
+					t.Errorf("Line(%s) = %s, want %s", tt.line, got, tt.want)
+				}
+			},
+		)
+	}
+}
+
+func TestLineKeepsJSONValid(t *testing.T) {
+	body := `{"user":"bob","password":{"old":"a","new":["b","c"]},"items":[{"card":"1234"},{"token":true}]}`
+
+	got := newTestRedactor().Line([]byte(body))
+	if !json.Valid(got) {
+		t.Fatalf("Line(%s) = %s, not valid json", body, got)
+	}
+}
+
+func TestLineNilRedactor(t *testing.T) {
+	var r *Redactor
+	if got := string(r.Line([]byte(`password=secret`))); got != `password=secret` {
+		t.Errorf("Line = %s, want the line as is", got)
+	}
+}
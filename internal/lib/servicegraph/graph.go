@@ -0,0 +1,169 @@
+package servicegraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DurationBuckets are the upper bounds, in seconds, of the latency histogram of the edges
+var DurationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Edge is the traffic from a client service to a server service
+type Edge struct {
+	Client   string `json:"client"`
+	Server   string `json:"server"`
+	Requests int64  `json:"requests"`
+	Failed   int64  `json:"failed"`
+	// Buckets counts the requests per DurationBuckets bound, the last one counts the slower requests
+	Buckets     []int64 `json:"buckets"`
+	DurationSum float64 `json:"duration_sum"`
+}
+
+// MeanDuration returns the mean latency of the requests
+func (e Edge) MeanDuration() time.Duration {
+	if e.Requests == 0 {
+		return 0
+	}
+	return time.Duration(e.DurationSum / float64(e.Requests) * float64(time.Second))
+}
+
+type edgeKey struct {
+	client, server string
+}
+
+// Graph aggregates the requests between the services, it is safe for concurrent use.
+type Graph struct {
+	mu    sync.Mutex
+	edges map[edgeKey]*Edge
+}
+
+func New() *Graph {
+	return &Graph{edges: map[edgeKey]*Edge{}}
+}
+
+// Add records a request from the client to the server
+func (g *Graph) Add(client, server string, duration time.Duration, failed bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := edgeKey{client, server}
+	edge, ok := g.edges[key]
+	if !ok {
+		edge = &Edge{Client: client, Server: server, Buckets: make([]int64, len(DurationBuckets)+1)}
+		g.edges[key] = edge
+	}
+
+	edge.Requests++
+	if failed {
+		edge.Failed++
+	}
+
+	seconds := duration.Seconds()
+	edge.DurationSum += seconds
+	edge.Buckets[sort.SearchFloat64s(DurationBuckets, seconds)]++
+}
+
+// Edges returns a copy of the edges sorted by client and server
+func (g *Graph) Edges() []Edge {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	edges := make([]Edge, 0, len(g.edges))
+	for _, edge := range g.edges {
+		e := *edge
+		e.Buckets = append([]int64(nil), edge.Buckets...)
+		edges = append(edges, e)
+	}
+
+	sort.Slice(
+		edges, func(i, j int) bool {
+			if edges[i].Client != edges[j].Client {
+				return edges[i].Client < edges[j].Client
+			}
+			return edges[i].Server < edges[j].Server
+		},
+	)
+
+	return edges
+}
+
+// WriteJSON writes the services and the edges as json
+func (g *Graph) WriteJSON(w io.Writer) error {
+	edges := g.Edges()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(
+		struct {
+			Services []string  `json:"services"`
+			Buckets  []float64 `json:"buckets"`
+			Edges    []Edge    `json:"edges"`
+		}{services(edges), DurationBuckets, edges},
+	)
+}
+
+// WriteDOT writes the graph in the graphviz format, e.g. to render it with `dot -Tsvg`
+func (g *Graph) WriteDOT(w io.Writer) error {
+	edges := g.Edges()
+
+	var b strings.Builder
+	b.WriteString("digraph services {\n  rankdir=LR;\n  node [shape=box];\n")
+	for _, service := range services(edges) {
+		fmt.Fprintf(&b, "  %q;\n", service)
+	}
+	for _, edge := range edges {
+		color := "black"
+		if edge.Failed > 0 {
+			color = "red"
+		}
+		fmt.Fprintf(
+			&b, "  %q -> %q [label=%q, color=%s];\n",
+			edge.Client, edge.Server,
+			fmt.Sprintf("%d req, %d failed, %s avg", edge.Requests, edge.Failed, edge.MeanDuration().Round(time.Microsecond)),
+			color,
+		)
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ServeHTTP writes the graph as json, or as dot with ?format=dot
+func (g *Graph) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var err error
+	if r.URL.Query().Get("format") == "dot" {
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		err = g.WriteDOT(w)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		err = g.WriteJSON(w)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func services(edges []Edge) []string {
+	set := map[string]struct{}{}
+	for _, edge := range edges {
+		set[edge.Client] = struct{}{}
+		set[edge.Server] = struct{}{}
+	}
+
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,41 @@
+package servicegraph
+
+import (
+	"github.com/username/otel-playground/internal/lib/otlpjson"
+)
+
+// FromSpans pairs the client spans with the server spans they are the parent of, across services.
+// The client spans without a server span, e.g. calls to services that aren't traced or whose spans were
+// sampled out, are attributed to their server.address.
+func FromSpans(spans []otlpjson.Span) *Graph {
+	clients := map[string]otlpjson.Span{}
+	for _, span := range spans {
+		if span.Kind == "client" {
+			clients[span.TraceID+span.SpanID] = span
+		}
+	}
+
+	g := New()
+	paired := map[string]bool{}
+	for _, server := range spans {
+		if server.Kind != "server" || server.ParentSpanID == "" {
+			continue
+		}
+
+		client, ok := clients[server.TraceID+server.ParentSpanID]
+		if !ok || client.Service == server.Service {
+			continue
+		}
+
+		paired[client.TraceID+client.SpanID] = true
+		g.Add(client.Service, server.Service, client.Duration(), client.Failed || server.Failed)
+	}
+
+	for key, client := range clients {
+		if address := client.Attributes["server.address"]; !paired[key] && address != "" {
+			g.Add(client.Service, address, client.Duration(), client.Failed)
+		}
+	}
+
+	return g
+}
@@ -2,19 +2,26 @@ package telemetry
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/sdk/resource"
+
+	"github.com/username/otel-playground/internal/lib/servicegraph"
 )
 
-type setupFunc func(context.Context, *resource.Resource) (func(context.Context) error, error)
+type setupFunc func(context.Context, Config, *resource.Resource) (func(context.Context) error, error)
 
 type Client struct {
 	config        Config
+	status        Status
 	shutdownFuncs []func(context.Context) error
 }
 
+// Configure sets up the enabled signals. A signal that fails is reported in the status and logged,
+// the others keep working; in strict mode Configure returns the joined errors instead.
 func Configure(ctx context.Context, opts ...Option) (Client, error) {
 	cfg := newConfig(opts...)
 
@@ -28,17 +35,50 @@ func Configure(ctx context.Context, opts ...Option) (Client, error) {
 		otel.SetErrorHandler(cfg.errorHandler)
 	}
 
-	for _, setup := range []setupFunc{configureMetrics, configureTracing} {
-		shutdown, err := setup(ctx, res)
+	signals := []struct {
+		name    string
+		enabled bool
+		setup   setupFunc
+	}{
+		{"metrics", cfg.metricsEnabled, configureMetrics},
+		{"tracing", cfg.tracingEnabled, configureTracing},
+		{"profiling", cfg.profiling.enabled(), configureProfiling},
+	}
+
+	var errs []error
+	for _, signal := range signals {
+		if !signal.enabled {
+			c.status.Signals = append(c.status.Signals, SignalStatus{Name: signal.name, State: StateDisabled})
+			continue
+		}
+
+		shutdown, err := signal.setup(ctx, cfg, res)
 		if err != nil {
+			err = fmt.Errorf("failed to configure %s: %w", signal.name, err)
+			errs = append(errs, err)
+			c.status.Signals = append(
+				c.status.Signals, SignalStatus{Name: signal.name, State: StateFailed, Error: err.Error()},
+			)
 			continue
 		}
 
+		c.status.Signals = append(c.status.Signals, SignalStatus{Name: signal.name, State: StateActive})
 		if shutdown != nil {
 			c.shutdownFuncs = append(c.shutdownFuncs, shutdown)
 		}
 	}
 
+	if len(errs) > 0 {
+		if cfg.strict {
+			c.Shutdown(ctx)
+			return Client{}, errors.Join(errs...)
+		}
+
+		for _, err := range errs {
+			log.Printf("telemetry degraded: %v\n", err)
+		}
+	}
+
 	return c, nil
 }
 
@@ -49,3 +89,84 @@ func (c Client) Shutdown(ctx context.Context) {
 		}
 	}
 }
+
+// OnError subscribes fn to the errors of the default error handler, e.g. to flip the readiness
+// while the collector is unreachable. It is a no-op when WithErrorHandler replaced the handler.
+func (c Client) OnError(fn func(ErrorEvent)) {
+	if handler, ok := c.config.errorHandler.(*ErrorHandler); ok {
+		handler.Subscribe(fn)
+	}
+}
+
+// ServiceGraph returns the calls of the service to the others, nil when WithServiceGraph turned it off
+func (c Client) ServiceGraph() *servicegraph.Graph {
+	return c.config.serviceGraph
+}
+
+// Status reports which signals are active
+func (c Client) Status() Status {
+	return c.status
+}
+
+const (
+	StateActive   = "active"
+	StateDisabled = "disabled"
+	StateFailed   = "failed"
+)
+
+// SignalStatus is the outcome of the setup of a signal
+type SignalStatus struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	Error string `json:"error,omitempty"`
+}
+
+// Status describes the signals configured by Configure.
+type Status struct {
+	Signals []SignalStatus `json:"signals"`
+}
+
+// Err joins the setup errors of the failed signals, nil when none failed.
+func (s Status) Err() error {
+	var errs []error
+	for _, signal := range s.Signals {
+		if signal.State == StateFailed {
+			errs = append(errs, errors.New(signal.Error))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Info describes the effective telemetry configuration.
+type Info struct {
+	ServiceName    string         `json:"service_name"`
+	ServiceVersion string         `json:"service_version"`
+	MetricsEnabled bool           `json:"metrics_enabled"`
+	TracingEnabled bool           `json:"tracing_enabled"`
+	ExporterTLS    bool           `json:"exporter_tls"`
+	Exporters      Exporters      `json:"exporters"`
+	Profiling      bool           `json:"profiling"`
+	Prometheus     string         `json:"prometheus,omitempty"`
+	Strict         bool           `json:"strict"`
+	Propagators    []string       `json:"propagators"`
+	Pipelines      int            `json:"pipelines"`
+	Signals        []SignalStatus `json:"signals"`
+}
+
+func (c Client) Info() Info {
+	return Info{
+		ServiceName:    c.config.serviceName,
+		ServiceVersion: c.config.serviceVersion,
+		MetricsEnabled: c.config.metricsEnabled,
+		TracingEnabled: c.config.tracingEnabled,
+		ExporterTLS:    c.config.exporterTLS != nil,
+		Exporters:      c.config.exporterKinds(),
+		Profiling:      c.config.profiling.enabled(),
+		Prometheus:     c.config.prometheusAddr,
+		Strict:         c.config.strict,
+		Propagators:    propagatorNames(c.config.propagators),
+		Pipelines:      len(c.shutdownFuncs),
+		Signals:        c.status.Signals,
+	}
+}
@@ -1,16 +1,43 @@
 package telemetry
 
 import (
+	"crypto/tls"
+	"time"
+
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	"github.com/username/otel-playground/internal/lib/redact"
+	"github.com/username/otel-playground/internal/lib/servicegraph"
 )
 
 type (
 	Config struct {
-		serviceName    string
-		serviceVersion string
-		metricsEnabled bool
-		tracingEnabled bool
-		errorHandler   otel.ErrorHandler
+		serviceName     string
+		serviceVersion  string
+		metricsEnabled  bool
+		tracingEnabled  bool
+		errorHandler    otel.ErrorHandler
+		exporterTLS     *tls.Config
+		profiling       ProfilingConfig
+		prometheusAddr  string
+		views           []View
+		maxCardinality  int
+		metricsInterval time.Duration
+		temporality     sdkmetric.TemporalitySelector
+		exemplars       bool
+		strict          bool
+		propagators     []string
+
+		spanBaggage            []string
+		spanResourceAttributes []attribute.Key
+		redactor               *redact.Redactor
+		tailSampling           TailSamplingConfig
+		spanMetrics            bool
+		serviceGraph           *servicegraph.Graph
+		exporters              map[Signal][]ExporterConfig
 	}
 
 	Option func(*Config)
@@ -20,9 +47,14 @@ func newConfig(opts ...Option) Config {
 	var defaultOpts []Option
 
 	c := Config{
-		tracingEnabled: true,
-		metricsEnabled: true,
-		errorHandler:   errorHandler{},
+		tracingEnabled:  true,
+		metricsEnabled:  true,
+		errorHandler:    NewErrorHandler(),
+		maxCardinality:  defaultCardinalityLimit,
+		metricsInterval: 10 * time.Second,
+		exemplars:       true,
+		spanMetrics:     true,
+		serviceGraph:    servicegraph.New(),
 	}
 
 	for _, opt := range append(defaultOpts, opts...) {
@@ -53,14 +85,166 @@ func WithServiceVersion(version string) Option {
 	}
 }
 
+// WithMetricsEnabled turns the metrics off, the instruments then record into a no-op provider
 func WithMetricsEnabled(enabled bool) Option {
 	return func(c *Config) {
 		c.metricsEnabled = enabled
 	}
 }
 
+// WithTracingEnabled turns the tracing off, the spans are then neither recorded nor propagated
 func WithTracingEnabled(enabled bool) Option {
 	return func(c *Config) {
 		c.tracingEnabled = enabled
 	}
 }
+
+// WithExporter configures where the signal is exported, to every exporter when there are several,
+// e.g. to otlp and to a file for the post-mortem analysis of a load test. It defaults to otlp alone.
+func WithExporter(signal Signal, exporters ...ExporterConfig) Option {
+	return func(c *Config) {
+		if c.exporters == nil {
+			c.exporters = map[Signal][]ExporterConfig{}
+		}
+		c.exporters[signal] = append([]ExporterConfig{}, exporters...)
+	}
+}
+
+// WithExporterTLS configures the tls used to connect to the collector, nil connects without tls.
+func WithExporterTLS(cfg *tls.Config) Option {
+	return func(c *Config) {
+		c.exporterTLS = cfg
+	}
+}
+
+// WithProfiling configures the continuous profiler, it stays disabled when neither a dir nor an endpoint is set.
+func WithProfiling(cfg ProfilingConfig) Option {
+	return func(c *Config) {
+		c.profiling = cfg
+	}
+}
+
+// WithPrometheusEndpoint serves the metrics for Prometheus to pull on the given address, e.g. ":9464",
+// besides pushing them to the collector. An empty address disables it.
+func WithPrometheusEndpoint(addr string) Option {
+	return func(c *Config) {
+		c.prometheusAddr = addr
+	}
+}
+
+// WithViews renames, drops attributes from or sets the histogram buckets of the matching instruments,
+// a later view for the same instrument replaces the earlier one.
+func WithViews(views ...View) Option {
+	return func(c *Config) {
+		c.views = append(c.views, views...)
+	}
+}
+
+// WithCardinalityLimit caps the distinct attribute sets exported per instrument, the measurements
+// above the limit are aggregated without their attributes and reported by otel.cardinality_limit_reached.
+// A limit of 0 disables the guard.
+func WithCardinalityLimit(limit int) Option {
+	return func(c *Config) {
+		c.maxCardinality = limit
+	}
+}
+
+// WithMetricsInterval configures how often the metrics are pushed to the collector
+func WithMetricsInterval(interval time.Duration) Option {
+	return func(c *Config) {
+		c.metricsInterval = interval
+	}
+}
+
+// WithTemporality selects the temporality of the pushed metrics per instrument kind, e.g. DeltaTemporality.
+// Nil follows OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE and defaults to cumulative.
+func WithTemporality(selector sdkmetric.TemporalitySelector) Option {
+	return func(c *Config) {
+		c.temporality = selector
+	}
+}
+
+// WithExemplars attaches the trace and span ids of sampled spans to the measurements recorded within them
+func WithExemplars(enabled bool) Option {
+	return func(c *Config) {
+		c.exemplars = enabled
+	}
+}
+
+// CumulativeTemporality exports every instrument as cumulative
+func CumulativeTemporality(sdkmetric.InstrumentKind) metricdata.Temporality {
+	return metricdata.CumulativeTemporality
+}
+
+// DeltaTemporality exports the counters and histograms as delta, the up-down counters stay cumulative
+// since their deltas can't be summed back into a meaningful value.
+func DeltaTemporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	switch kind {
+	case sdkmetric.InstrumentKindUpDownCounter, sdkmetric.InstrumentKindObservableUpDownCounter:
+		return metricdata.CumulativeTemporality
+	default:
+		return metricdata.DeltaTemporality
+	}
+}
+
+// WithStrict makes Configure fail when a signal can't be set up, instead of running degraded
+func WithStrict(strict bool) Option {
+	return func(c *Config) {
+		c.strict = strict
+	}
+}
+
+// WithPropagators configures the context propagation formats, e.g. "tracecontext", "baggage", "b3multi".
+// Without it OTEL_PROPAGATORS is read and defaults to "tracecontext,baggage".
+func WithPropagators(names ...string) Option {
+	return func(c *Config) {
+		c.propagators = append([]string{}, names...)
+	}
+}
+
+// WithSpanBaggage adds the given baggage members to every span as "baggage.<key>" attributes
+func WithSpanBaggage(keys ...string) Option {
+	return func(c *Config) {
+		c.spanBaggage = append(c.spanBaggage, keys...)
+	}
+}
+
+// WithSpanResourceAttributes copies the given resource attributes to every span,
+// for the backends that can't query the spans by their resource
+func WithSpanResourceAttributes(keys ...attribute.Key) Option {
+	return func(c *Config) {
+		c.spanResourceAttributes = append(c.spanResourceAttributes, keys...)
+	}
+}
+
+// WithRedactor drops, hashes or masks the span and span event attributes matching its rules before they are exported,
+// a nil redactor exports them as is
+func WithRedactor(redactor *redact.Redactor) Option {
+	return func(c *Config) {
+		c.redactor = redactor
+	}
+}
+
+// WithTailSampling buffers the spans of each trace for the decision window and exports the traces with an error,
+// a slow span or a span matching a predicate, plus a share of the others
+func WithTailSampling(cfg TailSamplingConfig) Option {
+	return func(c *Config) {
+		c.tailSampling = cfg
+	}
+}
+
+// WithSpanMetrics turns off the span.calls and span.duration metrics recorded for every span
+func WithSpanMetrics(enabled bool) Option {
+	return func(c *Config) {
+		c.spanMetrics = enabled
+	}
+}
+
+// WithServiceGraph turns off the service_graph metrics and the graph of the calls to the other services
+func WithServiceGraph(enabled bool) Option {
+	return func(c *Config) {
+		if !enabled {
+			c.serviceGraph = nil
+		}
+	}
+}
@@ -1,12 +1,217 @@
 package telemetry
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// ErrorKind classifies the errors reported by the OpenTelemetry SDK
+type ErrorKind string
+
+const (
+	ErrorKindExport           ErrorKind = "export"
+	ErrorKindDroppedSpans     ErrorKind = "dropped_spans"
+	ErrorKindInvalidAttribute ErrorKind = "invalid_attribute"
+	ErrorKindOther            ErrorKind = "other"
+)
+
+// ErrorEvent is sent to the subscribers of the error handler
+type ErrorEvent struct {
+	Kind ErrorKind
+	Err  error
+	Time time.Time
+}
+
+type (
+	errorHandlerConfig struct {
+		window      time.Duration
+		maxLogLines int
+	}
+
+	ErrorHandlerOption func(*errorHandlerConfig)
 )
 
-type errorHandler struct {
+// WithLogWindow configures the window in which an error is logged once, 1 minute by default
+func WithLogWindow(window time.Duration) ErrorHandlerOption {
+	return func(c *errorHandlerConfig) {
+		c.window = window
+	}
+}
+
+// WithMaxLogLines caps the errors logged per window whatever their message, 10 by default
+func WithMaxLogLines(lines int) ErrorHandlerOption {
+	return func(c *errorHandlerConfig) {
+		c.maxLogLines = lines
+	}
+}
+
+// ErrorHandler counts the SDK errors by kind, logs each distinct error once per window
+// with the number of occurrences it suppressed, and notifies the subscribers of every error.
+type ErrorHandler struct {
+	config errorHandlerConfig
+
+	mu          sync.Mutex
+	counts      map[ErrorKind]int64
+	logged      map[string]*loggedError
+	windowStart time.Time
+	windowLines int
+	dropped     int
+	subscribers []func(ErrorEvent)
+}
+
+type loggedError struct {
+	at         time.Time
+	suppressed int
+}
+
+var _ otel.ErrorHandler = (*ErrorHandler)(nil)
+
+// NewErrorHandler returns the default error handler of Configure
+func NewErrorHandler(opts ...ErrorHandlerOption) *ErrorHandler {
+	cfg := errorHandlerConfig{
+		window:      time.Minute,
+		maxLogLines: 10,
+	}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &ErrorHandler{
+		config: cfg,
+		counts: map[ErrorKind]int64{},
+		logged: map[string]*loggedError{},
+	}
+}
+
+// Subscribe calls fn for every error, from the goroutine reporting it, so fn must not block.
+func (h *ErrorHandler) Subscribe(fn func(ErrorEvent)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.subscribers = append(h.subscribers, fn)
+}
+
+func (h *ErrorHandler) Handle(err error) {
+	if err == nil {
+		return
+	}
+
+	event := ErrorEvent{Kind: classifyError(err), Err: err, Time: time.Now()}
+
+	h.mu.Lock()
+	h.counts[event.Kind]++
+	line := h.logLine(event)
+	subscribers := h.subscribers
+	h.mu.Unlock()
+
+	if line != "" {
+		log.Print(line)
+	}
+
+	for _, fn := range subscribers {
+		fn(event)
+	}
 }
 
-func (e errorHandler) Handle(err error) {
-	log.Printf("otel error handler: %v\n", err)
+// logLine returns the line to log for the event, empty when it is suppressed.
+func (h *ErrorHandler) logLine(event ErrorEvent) string {
+	if event.Time.Sub(h.windowStart) >= h.config.window {
+		h.windowStart, h.windowLines = event.Time, 0
+
+		// the errors that stopped occurring are forgotten, their suppressed count is lost
+		for key, logged := range h.logged {
+			if logged.suppressed == 0 && event.Time.Sub(logged.at) >= h.config.window {
+				delete(h.logged, key)
+			}
+		}
+	}
+
+	key := string(event.Kind) + ":" + fingerprint(event.Err)
+	previous, ok := h.logged[key]
+	if ok && event.Time.Sub(previous.at) < h.config.window {
+		previous.suppressed++
+		return ""
+	}
+
+	if h.windowLines >= h.config.maxLogLines {
+		h.dropped++
+		return ""
+	}
+	h.windowLines++
+	h.logged[key] = &loggedError{at: event.Time}
+
+	var details []string
+	if ok && previous.suppressed > 0 {
+		details = append(details, fmt.Sprintf("%d similar errors suppressed", previous.suppressed))
+	}
+	if h.dropped > 0 {
+		details = append(details, fmt.Sprintf("%d errors dropped by the rate limit", h.dropped))
+		h.dropped = 0
+	}
+
+	line := fmt.Sprintf("otel error handler: %s: %v", event.Kind, event.Err)
+	if len(details) > 0 {
+		line += " (" + strings.Join(details, ", ") + ")"
+	}
+
+	return line + "\n"
+}
+
+// registerCounter reports the handled errors as the otel.sdk.errors counter.
+func (h *ErrorHandler) registerCounter(meter metric.Meter) error {
+	_, err := meter.Int64ObservableCounter(
+		"otel.sdk.errors",
+		metric.WithDescription("errors reported by the OpenTelemetry SDK"),
+		metric.WithUnit("{error}"),
+		metric.WithInt64Callback(
+			func(_ context.Context, observer metric.Int64Observer) error {
+				h.mu.Lock()
+				defer h.mu.Unlock()
+
+				for kind, count := range h.counts {
+					observer.Observe(count, metric.WithAttributes(attribute.String("error.kind", string(kind))))
+				}
+				return nil
+			},
+		),
+	)
+
+	return err
+}
+
+// digits are left out of the fingerprint, the messages carry counts, ports and durations.
+var digits = regexp.MustCompile(`[0-9]+`)
+
+func fingerprint(err error) string {
+	return digits.ReplaceAllString(err.Error(), "#")
+}
+
+func classifyError(err error) ErrorKind {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorKindExport
+	}
+
+	message := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(message, "dropped") || strings.Contains(message, "queue is full"):
+		return ErrorKindDroppedSpans
+	case strings.Contains(message, "export") || strings.Contains(message, "upload") ||
+		strings.Contains(message, "rpc error") || strings.Contains(message, "connection"):
+		return ErrorKindExport
+	case strings.Contains(message, "attribute") || strings.Contains(message, "invalid"):
+		return ErrorKindInvalidAttribute
+	default:
+		return ErrorKindOther
+	}
 }
@@ -0,0 +1,282 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/username/otel-playground/internal/lib/environment"
+)
+
+// Signal is a signal whose exporters are configured by WithExporter
+type Signal string
+
+const (
+	SignalTraces  Signal = "traces"
+	SignalMetrics Signal = "metrics"
+)
+
+// The kinds of exporters
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterNone   = "none"
+)
+
+// The formats of the stdout and file exporters
+const (
+	// FormatPretty writes a line per span or data point for humans
+	FormatPretty = "pretty"
+	// FormatJSONL writes the spans as OTLP/JSON lines and the data points as flattened points, one per line,
+	// the formats of the otlpsink files, readable by tracectl and servicegraph
+	FormatJSONL = "jsonl"
+)
+
+// ExporterConfig configures an exporter of a signal
+type ExporterConfig struct {
+	// Kind is ExporterOTLP, ExporterStdout, ExporterFile or ExporterNone
+	Kind string
+	// Format is FormatPretty or FormatJSONL, stdout defaults to pretty and file to jsonl
+	Format string
+	// Dir is where the file exporter writes <service name>-<signal>.<jsonl|log>, the working directory by default
+	Dir string
+	// MaxBytes rotates the file once it would grow beyond it, 0 never rotates
+	MaxBytes int64
+	// MaxBackups is the number of rotated files kept, as <file>.1 to <file>.<MaxBackups>
+	MaxBackups int
+}
+
+// ExportersFromEnv reads the exporters of the signal from OTEL_TRACES_EXPORTER or OTEL_METRICS_EXPORTER,
+// a comma separated list of kinds defaulting to otlp, e.g. "otlp,file". The stdout and file exporters are
+// configured by the EXPORT_STDOUT_FORMAT and EXPORT_FILE_* environment variables.
+func ExportersFromEnv(signal Signal) []ExporterConfig {
+	kinds := environment.Get("OTEL_"+strings.ToUpper(string(signal))+"_EXPORTER", ExporterOTLP)
+
+	var exporters []ExporterConfig
+	for _, kind := range strings.Split(kinds, ",") {
+		exporter := ExporterConfig{Kind: strings.ToLower(strings.TrimSpace(kind))}
+
+		switch exporter.Kind {
+		case ExporterStdout:
+			exporter.Format = environment.Get("EXPORT_STDOUT_FORMAT", FormatPretty)
+		case ExporterFile:
+			exporter.Format = environment.Get("EXPORT_FILE_FORMAT", FormatJSONL)
+			exporter.Dir = environment.Get("EXPORT_FILE_DIR", "")
+			exporter.MaxBytes = int64(environment.Get("EXPORT_FILE_MAX_MB", 100)) << 20
+			exporter.MaxBackups = environment.Get("EXPORT_FILE_MAX_BACKUPS", 3)
+		}
+
+		exporters = append(exporters, exporter)
+	}
+
+	return exporters
+}
+
+// signalExporters returns the exporters of the signal, otlp when none is configured
+func (c Config) signalExporters(signal Signal) []ExporterConfig {
+	if exporters, ok := c.exporters[signal]; ok {
+		return exporters
+	}
+	return []ExporterConfig{{Kind: ExporterOTLP}}
+}
+
+// Exporters lists the kinds of exporters of the signals
+type Exporters struct {
+	Traces  []string `json:"traces"`
+	Metrics []string `json:"metrics"`
+}
+
+func (c Config) exporterKinds() Exporters {
+	kinds := func(signal Signal) []string {
+		var names []string
+		for _, e := range c.signalExporters(signal) {
+			names = append(names, e.Kind)
+		}
+		return names
+	}
+
+	return Exporters{Traces: kinds(SignalTraces), Metrics: kinds(SignalMetrics)}
+}
+
+// format returns the format of the stdout and file exporters, or an error when it is unknown
+func (e ExporterConfig) format() (string, error) {
+	switch e.Format {
+	case "":
+		if e.Kind == ExporterStdout {
+			return FormatPretty, nil
+		}
+		return FormatJSONL, nil
+	case FormatPretty, FormatJSONL:
+		return e.Format, nil
+	default:
+		return "", fmt.Errorf("unknown %s exporter format '%s'", e.Kind, e.Format)
+	}
+}
+
+// output opens where the stdout and file exporters write
+func (e ExporterConfig) output(service string, signal Signal) (writeCloser, error) {
+	format, err := e.format()
+	if err != nil {
+		return writeCloser{}, err
+	}
+
+	if e.Kind == ExporterStdout {
+		return writeCloser{Writer: os.Stdout, close: func() error { return nil }}, nil
+	}
+
+	ext := "jsonl"
+	if format == FormatPretty {
+		ext = "log"
+	}
+	if service == "" {
+		service = "unknown_service"
+	}
+
+	file, err := openRotatingFile(
+		filepath.Join(e.Dir, fmt.Sprintf("%s-%s.%s", service, signal, ext)), e.MaxBytes, e.MaxBackups,
+	)
+	if err != nil {
+		return writeCloser{}, err
+	}
+
+	return writeCloser{Writer: file, close: file.Close}, nil
+}
+
+// newSpanExporter creates the exporters of the spans, nil when they are all none.
+// Several exporters are fanned out to.
+func newSpanExporter(ctx context.Context, cfg Config) (sdktrace.SpanExporter, error) {
+	var exporters multiSpanExporter
+	for _, e := range cfg.signalExporters(SignalTraces) {
+		var (
+			exporter sdktrace.SpanExporter
+			err      error
+		)
+
+		switch e.Kind {
+		case ExporterNone:
+			continue
+		case ExporterOTLP:
+			exporter, err = newOTLPTraceExporter(ctx, cfg.exporterTLS)
+		case ExporterStdout, ExporterFile:
+			exporter, err = newWriterSpanExporter(e, cfg.serviceName)
+		default:
+			err = fmt.Errorf("unknown exporter '%s'", e.Kind)
+		}
+
+		if err != nil {
+			_ = exporters.Shutdown(ctx)
+			return nil, fmt.Errorf("%s: %w", e.Kind, err)
+		}
+		exporters = append(exporters, exporter)
+	}
+
+	switch len(exporters) {
+	case 0:
+		return nil, nil
+	case 1:
+		return exporters[0], nil
+	default:
+		return exporters, nil
+	}
+}
+
+// newMetricExporter creates the exporters of the metrics, nil when they are all none.
+// Several exporters are fanned out to.
+func newMetricExporter(ctx context.Context, cfg Config) (sdkmetric.Exporter, error) {
+	var exporters multiMetricExporter
+	for _, e := range cfg.signalExporters(SignalMetrics) {
+		var (
+			exporter sdkmetric.Exporter
+			err      error
+		)
+
+		switch e.Kind {
+		case ExporterNone:
+			continue
+		case ExporterOTLP:
+			exporter, err = newOTLPMetricExporter(ctx, cfg.exporterTLS, cfg.temporality)
+		case ExporterStdout, ExporterFile:
+			exporter, err = newWriterMetricExporter(e, cfg.serviceName, cfg.temporality)
+		default:
+			err = fmt.Errorf("unknown exporter '%s'", e.Kind)
+		}
+
+		if err != nil {
+			_ = exporters.Shutdown(ctx)
+			return nil, fmt.Errorf("%s: %w", e.Kind, err)
+		}
+		exporters = append(exporters, exporter)
+	}
+
+	switch len(exporters) {
+	case 0:
+		return nil, nil
+	case 1:
+		return exporters[0], nil
+	default:
+		return exporters, nil
+	}
+}
+
+// multiSpanExporter exports the spans to every exporter, one failing doesn't keep the others from exporting
+type multiSpanExporter []sdktrace.SpanExporter
+
+func (m multiSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	var errs []error
+	for _, exporter := range m {
+		errs = append(errs, exporter.ExportSpans(ctx, spans))
+	}
+	return errors.Join(errs...)
+}
+
+func (m multiSpanExporter) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, exporter := range m {
+		errs = append(errs, exporter.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// multiMetricExporter exports the metrics to every exporter, they all get the temporality and the aggregation
+// of the first one since the reader collects the metrics once
+type multiMetricExporter []sdkmetric.Exporter
+
+func (m multiMetricExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	return m[0].Temporality(kind)
+}
+
+func (m multiMetricExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return m[0].Aggregation(kind)
+}
+
+func (m multiMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	var errs []error
+	for _, exporter := range m {
+		errs = append(errs, exporter.Export(ctx, rm))
+	}
+	return errors.Join(errs...)
+}
+
+func (m multiMetricExporter) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, exporter := range m {
+		errs = append(errs, exporter.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+func (m multiMetricExporter) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, exporter := range m {
+		errs = append(errs, exporter.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
@@ -2,68 +2,128 @@ package telemetry
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 
 	hostMetrics "go.opentelemetry.io/contrib/instrumentation/host"
 	runtimemetrics "go.opentelemetry.io/contrib/instrumentation/runtime"
-	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
-	metricGlobal "go.opentelemetry.io/otel/metric/global"
-	sdkcontroller "go.opentelemetry.io/otel/sdk/metric/controller/basic"
-	sdkprocessor "go.opentelemetry.io/otel/sdk/metric/processor/basic"
-	sdkselector "go.opentelemetry.io/otel/sdk/metric/selector/simple"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
 	"go.opentelemetry.io/otel/sdk/resource"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/encoding/gzip"
 )
 
-func configureMetrics(ctx context.Context, resource *resource.Resource) (func(context.Context) error, error) {
-	exporter, err := newOTLPMetricExporter(ctx)
+func configureMetrics(ctx context.Context, cfg Config, resource *resource.Resource) (func(context.Context) error, error) {
+	exporter, err := newMetricExporter(ctx, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create metric exporter: %w", err)
+		return nil, fmt.Errorf("failed to create the metric exporters: %w", err)
 	}
 
-	pusher := sdkcontroller.New(
-		sdkprocessor.NewFactory(
-			sdkselector.NewWithInexpensiveDistribution(),
-			exporter,
-		),
-		sdkcontroller.WithExporter(exporter),
-		sdkcontroller.WithResource(resource),
-	)
-
-	if err := pusher.Start(ctx); err != nil {
-		return nil, fmt.Errorf("failed to start metric provider: %w", err)
+	// exemplars are only sampled from measurements recorded within a sampled span
+	exemplarFilter := exemplar.AlwaysOffFilter
+	if cfg.exemplars {
+		exemplarFilter = exemplar.TraceBasedFilter
 	}
 
-	if err := runtimemetrics.Start(runtimemetrics.WithMeterProvider(pusher)); err != nil {
-		return nil, fmt.Errorf("runtimemetrics.Start failed: %s", err)
+	opts := []sdkmetric.Option{
+		sdkmetric.WithResource(resource),
+		sdkmetric.WithView(newViews(cfg.views)...),
+		sdkmetric.WithExemplarFilter(exemplarFilter),
+		sdkmetric.WithCardinalityLimit(cfg.maxCardinality),
 	}
 
-	if err = hostMetrics.Start(hostMetrics.WithMeterProvider(pusher)); err != nil {
-		return nil, fmt.Errorf("failed to start host metrics: %v", err)
+	// without exporter the metrics are only pulled by Prometheus, if it is enabled, and the overflow series
+	// are looked for in metrics collected for nothing else
+	var overflow *overflowExporter
+	switch {
+	case exporter != nil:
+		overflow = newOverflowExporter(exporter)
+	case cfg.prometheusAddr != "":
+		overflow = newOverflowExporter(discardExporter{})
+	}
+	if overflow != nil {
+		opts = append(
+			opts, sdkmetric.WithReader(sdkmetric.NewPeriodicReader(overflow, sdkmetric.WithInterval(cfg.metricsInterval))),
+		)
 	}
 
-	metricGlobal.SetMeterProvider(pusher)
+	stopPrometheus := func(context.Context) error { return nil }
+	if cfg.prometheusAddr != "" {
+		reader, stop, err := servePrometheus(cfg.prometheusAddr)
+		if err != nil {
+			if exporter != nil {
+				_ = exporter.Shutdown(ctx)
+			}
+			return nil, err
+		}
+		opts = append(opts, sdkmetric.WithReader(reader))
+		stopPrometheus = stop
+	}
+
+	provider := sdkmetric.NewMeterProvider(opts...)
 
-	return func(ctx context.Context) (lastErr error) {
-		if err := pusher.Stop(ctx); err != nil {
+	shutdown := func(ctx context.Context) (lastErr error) {
+		if err := stopPrometheus(ctx); err != nil {
 			lastErr = err
 		}
 
-		if err := exporter.Shutdown(ctx); err != nil {
+		// flushes the periodic reader and shuts the exporter down
+		if err := provider.Shutdown(ctx); err != nil {
 			lastErr = err
 		}
 
 		return lastErr
-	}, nil
+	}
+
+	if overflow != nil {
+		if err := overflow.registerGauge(provider.Meter("otel-playground/telemetry")); err != nil {
+			_ = shutdown(ctx)
+			return nil, fmt.Errorf("failed to register the cardinality limit gauge: %w", err)
+		}
+	}
+
+	if handler, ok := cfg.errorHandler.(*ErrorHandler); ok {
+		if err := handler.registerCounter(provider.Meter("otel-playground/telemetry")); err != nil {
+			_ = shutdown(ctx)
+			return nil, fmt.Errorf("failed to register the sdk errors counter: %w", err)
+		}
+	}
+
+	if err := runtimemetrics.Start(runtimemetrics.WithMeterProvider(provider)); err != nil {
+		_ = shutdown(ctx)
+		return nil, fmt.Errorf("runtimemetrics.Start failed: %s", err)
+	}
+
+	if err = hostMetrics.Start(hostMetrics.WithMeterProvider(provider)); err != nil {
+		_ = shutdown(ctx)
+		return nil, fmt.Errorf("failed to start host metrics: %v", err)
+	}
+
+	otel.SetMeterProvider(provider)
+
+	return shutdown, nil
 }
 
-func newOTLPMetricExporter(ctx context.Context) (*otlpmetric.Exporter, error) {
-	return otlpmetric.New(
-		ctx,
-		otlpmetricgrpc.NewClient(
-			otlpmetricgrpc.WithCompressor(gzip.Name),
-			otlpmetricgrpc.WithInsecure(),
-		),
-	)
+func newOTLPMetricExporter(
+	ctx context.Context, tlsConfig *tls.Config, temporality sdkmetric.TemporalitySelector,
+) (sdkmetric.Exporter, error) {
+	security := otlpmetricgrpc.WithInsecure()
+	if tlsConfig != nil {
+		security = otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig))
+	}
+
+	opts := []otlpmetricgrpc.Option{
+		otlpmetricgrpc.WithCompressor(gzip.Name),
+		security,
+	}
+
+	// without a selector the exporter follows OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE
+	if temporality != nil {
+		opts = append(opts, otlpmetricgrpc.WithTemporalitySelector(temporality))
+	}
+
+	return otlpmetricgrpc.New(ctx, opts...)
 }
@@ -0,0 +1,221 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/username/otel-playground/internal/lib/environment"
+)
+
+// ProfilingConfig configures the continuous profiler, it is enabled when Dir or Endpoint is set.
+type ProfilingConfig struct {
+	// Dir is where the profiles are written to
+	Dir string
+	// Endpoint receives the profiles as POST requests
+	Endpoint string
+	// Interval between two captures
+	Interval time.Duration
+	// CPUDuration is how long the cpu is profiled on every capture
+	CPUDuration time.Duration
+	// Profiles are the captured profiles, any of "cpu", "heap", "goroutine", "mutex", "block"
+	Profiles []string
+}
+
+// ProfilingConfigFromEnv reads the profiler configuration from the PROFILING_* environment variables.
+func ProfilingConfigFromEnv() ProfilingConfig {
+	return ProfilingConfig{
+		Dir:         environment.Get("PROFILING_DIR", ""),
+		Endpoint:    environment.Get("PROFILING_ENDPOINT", ""),
+		Interval:    time.Duration(environment.Get("PROFILING_INTERVAL_SECONDS", 60)) * time.Second,
+		CPUDuration: time.Duration(environment.Get("PROFILING_CPU_SECONDS", 10)) * time.Second,
+		Profiles:    []string{"cpu", "heap", "goroutine"},
+	}
+}
+
+func (c ProfilingConfig) enabled() bool {
+	return c.Dir != "" || c.Endpoint != ""
+}
+
+var profilingEnabled int32
+
+// Do calls fn with the profiler samples labeled with the trace id, span id and name of the span of ctx.
+// Like pprof.Do, the labels only apply while fn runs, the goroutine gets its previous labels back when it returns.
+// fn is called directly when the profiler is disabled or ctx has no span.
+func Do(ctx context.Context, fn func(context.Context)) {
+	span := trace.SpanFromContext(ctx)
+	sc := span.SpanContext()
+	if atomic.LoadInt32(&profilingEnabled) == 0 || !sc.IsValid() {
+		fn(ctx)
+		return
+	}
+
+	labels := []string{"trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String()}
+	if named, ok := span.(interface{ Name() string }); ok {
+		labels = append(labels, "span_name", named.Name())
+	}
+
+	pprof.Do(ctx, pprof.Labels(labels...), fn)
+}
+
+type profiler struct {
+	config  ProfilingConfig
+	service string
+	client  *http.Client
+	stop    chan struct{}
+	done    sync.WaitGroup
+}
+
+func configureProfiling(_ context.Context, cfg Config, res *resource.Resource) (func(context.Context) error, error) {
+	if !cfg.profiling.enabled() {
+		return nil, nil
+	}
+
+	if cfg.profiling.Interval <= 0 || cfg.profiling.CPUDuration <= 0 {
+		return nil, fmt.Errorf(
+			"the profiling interval and cpu duration must be positive, got %s and %s",
+			cfg.profiling.Interval, cfg.profiling.CPUDuration,
+		)
+	}
+
+	if cfg.profiling.Dir != "" {
+		if err := os.MkdirAll(cfg.profiling.Dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create profiling dir: %w", err)
+		}
+	}
+
+	service, _ := res.Set().Value(semconv.ServiceNameKey)
+
+	p := &profiler{
+		config:  cfg.profiling,
+		service: service.AsString(),
+		client:  &http.Client{Timeout: 30 * time.Second},
+		stop:    make(chan struct{}),
+	}
+
+	atomic.StoreInt32(&profilingEnabled, 1)
+
+	p.done.Add(1)
+	go p.run()
+
+	return func(ctx context.Context) error {
+		atomic.StoreInt32(&profilingEnabled, 0)
+		close(p.stop)
+
+		done := make(chan struct{})
+		go func() {
+			p.done.Wait()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}, nil
+}
+
+func (p *profiler) run() {
+	defer p.done.Done()
+
+	ticker := time.NewTicker(p.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			p.capture()
+		}
+	}
+}
+
+func (p *profiler) capture() {
+	for _, name := range p.config.Profiles {
+		start := time.Now()
+
+		var buf bytes.Buffer
+		if err := p.profile(name, &buf); err != nil {
+			log.Printf("failed to capture %s profile: %v\n", name, err)
+			continue
+		}
+
+		if err := p.export(name, start, time.Now(), buf.Bytes()); err != nil {
+			log.Printf("failed to export %s profile: %v\n", name, err)
+		}
+	}
+}
+
+func (p *profiler) profile(name string, buf *bytes.Buffer) error {
+	if name != "cpu" {
+		profile := pprof.Lookup(name)
+		if profile == nil {
+			return fmt.Errorf("unknown profile '%s'", name)
+		}
+		return profile.WriteTo(buf, 0)
+	}
+
+	// fails when the cpu is already being profiled, e.g. from the admin endpoint
+	if err := pprof.StartCPUProfile(buf); err != nil {
+		return err
+	}
+
+	select {
+	case <-p.stop:
+	case <-time.After(p.config.CPUDuration):
+	}
+	pprof.StopCPUProfile()
+
+	return nil
+}
+
+func (p *profiler) export(name string, start, end time.Time, data []byte) error {
+	if p.config.Dir != "" {
+		file := filepath.Join(
+			p.config.Dir,
+			fmt.Sprintf("%s-%s-%s.pb.gz", p.service, name, start.UTC().Format("20060102T150405Z")),
+		)
+		if err := os.WriteFile(file, data, 0o644); err != nil {
+			return fmt.Errorf("failed to write '%s': %w", file, err)
+		}
+	}
+
+	if p.config.Endpoint == "" {
+		return nil
+	}
+
+	query := url.Values{}
+	query.Set("service", p.service)
+	query.Set("type", name)
+	query.Set("from", fmt.Sprint(start.Unix()))
+	query.Set("until", fmt.Sprint(end.Unix()))
+	query.Set("format", "pprof")
+
+	res, err := p.client.Post(p.config.Endpoint+"?"+query.Encode(), "application/octet-stream", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to push profile: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("failed to push profile: unexpected status %d", res.StatusCode)
+	}
+
+	return nil
+}
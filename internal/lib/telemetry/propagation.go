@@ -0,0 +1,45 @@
+package telemetry
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/contrib/propagators/autoprop"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+var defaultPropagators = []string{"tracecontext", "baggage"}
+
+// propagatorNames returns the propagators configured by WithPropagators, else by OTEL_PROPAGATORS,
+// else tracecontext and baggage.
+func propagatorNames(configured []string) []string {
+	names := configured
+	if len(names) == 0 {
+		names = defaultPropagators
+		if env := os.Getenv("OTEL_PROPAGATORS"); env != "" {
+			names = strings.Split(env, ",")
+		}
+	}
+
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			normalized = append(normalized, name)
+		}
+	}
+
+	return normalized
+}
+
+// newPropagator composes the named propagators: tracecontext, baggage, b3, b3multi, jaeger, ottrace, xray or none.
+// Each of them injects its headers and the extraction accepts any of them, so a trace stays connected
+// through services that propagate with different formats.
+func newPropagator(names []string) (propagation.TextMapPropagator, error) {
+	propagator, err := autoprop.TextMapPropagator(names...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid propagators '%s': %w", strings.Join(names, ","), err)
+	}
+
+	return propagator, nil
+}
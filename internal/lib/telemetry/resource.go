@@ -9,7 +9,7 @@ import (
 
 	"go.opentelemetry.io/otel/attribute"
 	sdkresource "go.opentelemetry.io/otel/sdk/resource"
-	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
 )
 
 type localMachineDetector struct{}
@@ -37,8 +37,8 @@ func (l localMachineDetector) Detect(_ context.Context) (*sdkresource.Resource,
 
 	return sdkresource.NewWithAttributes(
 		semconv.SchemaURL,
-		semconv.HostNameKey.String(hostname),
-		semconv.NetHostIPKey.String(ip.String()),
+		semconv.HostName(hostname),
+		semconv.HostIP(ip.String()),
 	), nil
 }
 
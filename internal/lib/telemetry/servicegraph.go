@@ -0,0 +1,105 @@
+package telemetry
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/username/otel-playground/internal/lib/servicegraph"
+)
+
+// serviceGraphProcessor records the calls of the service to the others from its client spans, the server is
+// the peer.service attribute or else the server.address, which is the service name in docker compose.
+// cmd/servicegraph pairs the client and server spans of all the services instead.
+type serviceGraphProcessor struct {
+	service  string
+	graph    *servicegraph.Graph
+	requests metric.Int64Counter
+	failed   metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+func newServiceGraphProcessor(
+	meter metric.Meter, res *resource.Resource, graph *servicegraph.Graph,
+) (*serviceGraphProcessor, error) {
+	service, _ := res.Set().Value(semconv.ServiceNameKey)
+	p := &serviceGraphProcessor{service: service.AsString(), graph: graph}
+
+	var err error
+	if p.requests, err = meter.Int64Counter(
+		"service_graph.requests",
+		metric.WithDescription("requests from the client to the server service"),
+		metric.WithUnit("{request}"),
+	); err != nil {
+		return nil, err
+	}
+
+	if p.failed, err = meter.Int64Counter(
+		"service_graph.failed_requests",
+		metric.WithDescription("failed requests from the client to the server service"),
+		metric.WithUnit("{request}"),
+	); err != nil {
+		return nil, err
+	}
+
+	if p.duration, err = meter.Float64Histogram(
+		"service_graph.request.duration",
+		metric.WithDescription("duration of the requests from the client to the server service, seen by the client"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(servicegraph.DurationBuckets...),
+	); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *serviceGraphProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (p *serviceGraphProcessor) OnEnd(span sdktrace.ReadOnlySpan) {
+	if span.SpanKind() != trace.SpanKindClient {
+		return
+	}
+
+	var server string
+	for _, attr := range span.Attributes() {
+		switch attr.Key {
+		case semconv.PeerServiceKey:
+			server = attr.Value.AsString()
+		case semconv.ServerAddressKey:
+			if server == "" {
+				server = attr.Value.AsString()
+			}
+		}
+	}
+	if server == "" {
+		return
+	}
+
+	duration := span.EndTime().Sub(span.StartTime())
+	failed := span.Status().Code == codes.Error
+	p.graph.Add(p.service, server, duration, failed)
+
+	ctx := trace.ContextWithSpanContext(context.Background(), span.SpanContext())
+	attrs := metric.WithAttributes(attribute.String("client", p.service), attribute.String("server", server))
+
+	p.requests.Add(ctx, 1, attrs)
+	if failed {
+		p.failed.Add(ctx, 1, attrs)
+	}
+	p.duration.Record(ctx, duration.Seconds(), attrs)
+}
+
+func (p *serviceGraphProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (p *serviceGraphProcessor) ForceFlush(context.Context) error {
+	return nil
+}
@@ -0,0 +1,73 @@
+package telemetry
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spanDurationBuckets spans the in-process operations to the slow downstream calls, in seconds
+var spanDurationBuckets = []float64{0.0001, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// spanMetricsProcessor records the rate, errors and duration of every span name (RED metrics),
+// the measurements of the sampled spans carry their trace as exemplar.
+type spanMetricsProcessor struct {
+	service  attribute.KeyValue
+	calls    metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+func newSpanMetricsProcessor(meter metric.Meter, res *resource.Resource) (*spanMetricsProcessor, error) {
+	service, _ := res.Set().Value(semconv.ServiceNameKey)
+	p := &spanMetricsProcessor{service: semconv.ServiceNameKey.String(service.AsString())}
+
+	var err error
+	if p.calls, err = meter.Int64Counter(
+		"span.calls",
+		metric.WithDescription("ended spans by name, kind and status"),
+		metric.WithUnit("{call}"),
+	); err != nil {
+		return nil, err
+	}
+
+	if p.duration, err = meter.Float64Histogram(
+		"span.duration",
+		metric.WithDescription("duration of the spans by name, kind and status"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(spanDurationBuckets...),
+	); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *spanMetricsProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (p *spanMetricsProcessor) OnEnd(span sdktrace.ReadOnlySpan) {
+	// the span context lets the exemplar filter link the measurements to the trace
+	ctx := trace.ContextWithSpanContext(context.Background(), span.SpanContext())
+
+	attrs := metric.WithAttributes(
+		p.service,
+		attribute.String("span.name", span.Name()),
+		attribute.String("span.kind", span.SpanKind().String()),
+		attribute.String("status.code", span.Status().Code.String()),
+	)
+
+	p.calls.Add(ctx, 1, attrs)
+	p.duration.Record(ctx, span.EndTime().Sub(span.StartTime()).Seconds(), attrs)
+}
+
+func (p *spanMetricsProcessor) Shutdown(context.Context) error {
+	return nil
+}
+
+func (p *spanMetricsProcessor) ForceFlush(context.Context) error {
+	return nil
+}
@@ -0,0 +1,106 @@
+package telemetry
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/username/otel-playground/internal/lib/redact"
+)
+
+// attributeProcessor adds the baggage and resource attributes to every span when it starts,
+// and redacts its attributes when it ends, before passing the span to the next processor.
+type attributeProcessor struct {
+	next        sdktrace.SpanProcessor
+	baggageKeys []string
+	resource    []attribute.KeyValue
+	redactor    *redact.Redactor
+}
+
+func newAttributeProcessor(next sdktrace.SpanProcessor, cfg Config, res *resource.Resource) sdktrace.SpanProcessor {
+	if len(cfg.spanBaggage) == 0 && len(cfg.spanResourceAttributes) == 0 && cfg.redactor == nil {
+		return next
+	}
+
+	p := &attributeProcessor{
+		next:        next,
+		baggageKeys: cfg.spanBaggage,
+		redactor:    cfg.redactor,
+	}
+
+	for _, key := range cfg.spanResourceAttributes {
+		if value, ok := res.Set().Value(key); ok {
+			p.resource = append(p.resource, attribute.KeyValue{Key: key, Value: value})
+		}
+	}
+
+	return p
+}
+
+func (p *attributeProcessor) OnStart(ctx context.Context, span sdktrace.ReadWriteSpan) {
+	span.SetAttributes(p.resource...)
+
+	bag := baggage.FromContext(ctx)
+	for _, key := range p.baggageKeys {
+		if member := bag.Member(key); member.Key() != "" {
+			span.SetAttributes(attribute.String("baggage."+key, member.Value()))
+		}
+	}
+
+	p.next.OnStart(ctx, span)
+}
+
+func (p *attributeProcessor) OnEnd(span sdktrace.ReadOnlySpan) {
+	if p.redactor != nil {
+		events := span.Events()
+		for i := range events {
+			events[i].Attributes = p.apply(events[i].Attributes)
+		}
+		span = redactedSpan{ReadOnlySpan: span, attributes: p.apply(span.Attributes()), events: events}
+	}
+
+	p.next.OnEnd(span)
+}
+
+func (p *attributeProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p *attributeProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// apply returns the attributes redacted by the rules matching their key, without the dropped ones
+func (p *attributeProcessor) apply(attrs []attribute.KeyValue) []attribute.KeyValue {
+	result := make([]attribute.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		if _, ok := p.redactor.Match(string(attr.Key)); !ok {
+			result = append(result, attr)
+			continue
+		}
+
+		if value, ok := p.redactor.Value(string(attr.Key), attr.Value.Emit()); ok {
+			result = append(result, attr.Key.String(value))
+		}
+	}
+
+	return result
+}
+
+// redactedSpan exposes the redacted attributes of an ended span, the span itself can't be modified anymore.
+type redactedSpan struct {
+	sdktrace.ReadOnlySpan
+	attributes []attribute.KeyValue
+	events     []sdktrace.Event
+}
+
+func (s redactedSpan) Attributes() []attribute.KeyValue {
+	return s.attributes
+}
+
+func (s redactedSpan) Events() []sdktrace.Event {
+	return s.events
+}
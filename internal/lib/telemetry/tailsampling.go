@@ -0,0 +1,331 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/username/otel-playground/internal/lib/environment"
+)
+
+// SpanPredicate keeps the traces with a span it matches
+type SpanPredicate func(sdktrace.ReadOnlySpan) bool
+
+// AttributeEquals matches the spans with the given attribute
+func AttributeEquals(attr attribute.KeyValue) SpanPredicate {
+	return func(span sdktrace.ReadOnlySpan) bool {
+		for _, a := range span.Attributes() {
+			if a == attr {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// TailSamplingConfig configures the tail sampling, it is enabled when Window is set.
+// Only the spans of the service are seen, each service decides on its part of the trace.
+type TailSamplingConfig struct {
+	// Window is how long the spans of a trace are buffered after its first span ended
+	Window time.Duration
+	// Latency keeps the traces with a span lasting longer, 0 disables the rule
+	Latency time.Duration
+	// Predicates keep the traces with a span matching any of them
+	Predicates []SpanPredicate
+	// Probability is the share of the other traces that is kept, chosen by trace id so the services agree
+	Probability float64
+	// MaxSpans bounds the buffered spans, the oldest traces are decided early beyond it
+	MaxSpans int
+}
+
+// TailSamplingConfigFromEnv reads the tail sampling configuration from the TAIL_SAMPLING_* environment variables.
+func TailSamplingConfigFromEnv() TailSamplingConfig {
+	return TailSamplingConfig{
+		Window:      time.Duration(environment.Get("TAIL_SAMPLING_WINDOW_SECONDS", 0)) * time.Second,
+		Latency:     time.Duration(environment.Get("TAIL_SAMPLING_LATENCY_MS", 100)) * time.Millisecond,
+		Probability: environment.Get("TAIL_SAMPLING_PROBABILITY", 0.1),
+		MaxSpans:    environment.Get("TAIL_SAMPLING_MAX_SPANS", 10000),
+	}
+}
+
+func (c TailSamplingConfig) enabled() bool {
+	return c.Window > 0
+}
+
+const (
+	decisionSampled = "sampled"
+	decisionDropped = "dropped"
+)
+
+type pendingTrace struct {
+	id    trace.TraceID
+	start time.Time
+	spans []sdktrace.ReadOnlySpan
+}
+
+type decision struct {
+	keep bool
+	at   time.Time
+}
+
+type decisionKey struct {
+	decision, reason string
+}
+
+// tailSamplingProcessor buffers the spans per trace for the decision window, then passes the spans
+// of the kept traces to the next processor. The spans ending after the decision follow it.
+type tailSamplingProcessor struct {
+	config TailSamplingConfig
+	next   sdktrace.SpanProcessor
+
+	mu        sync.Mutex
+	pending   map[trace.TraceID]*pendingTrace
+	order     []*pendingTrace
+	decided   map[trace.TraceID]decision
+	buffered  int
+	decisions map[decisionKey]int64
+	evicted   int64
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func newTailSamplingProcessor(next sdktrace.SpanProcessor, cfg TailSamplingConfig) *tailSamplingProcessor {
+	p := &tailSamplingProcessor{
+		config:    cfg,
+		next:      next,
+		pending:   map[trace.TraceID]*pendingTrace{},
+		decided:   map[trace.TraceID]decision{},
+		decisions: map[decisionKey]int64{},
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	go p.run()
+
+	return p
+}
+
+func (p *tailSamplingProcessor) OnStart(ctx context.Context, span sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, span)
+}
+
+func (p *tailSamplingProcessor) OnEnd(span sdktrace.ReadOnlySpan) {
+	id := span.SpanContext().TraceID()
+
+	p.mu.Lock()
+	if d, ok := p.decided[id]; ok {
+		p.mu.Unlock()
+		if d.keep {
+			p.next.OnEnd(span)
+		}
+		return
+	}
+
+	t, ok := p.pending[id]
+	if !ok {
+		t = &pendingTrace{id: id, start: time.Now()}
+		p.pending[id] = t
+		p.order = append(p.order, t)
+	}
+	t.spans = append(t.spans, span)
+	p.buffered++
+
+	var kept []sdktrace.ReadOnlySpan
+	for p.config.MaxSpans > 0 && p.buffered > p.config.MaxSpans && len(p.order) > 0 {
+		p.evicted++
+		kept = append(kept, p.decideOldest()...)
+	}
+	p.mu.Unlock()
+
+	p.export(kept)
+}
+
+func (p *tailSamplingProcessor) Shutdown(ctx context.Context) error {
+	p.stopOnce.Do(func() { close(p.stop) })
+	<-p.done
+
+	p.flush()
+
+	return p.next.Shutdown(ctx)
+}
+
+func (p *tailSamplingProcessor) ForceFlush(ctx context.Context) error {
+	p.flush()
+
+	return p.next.ForceFlush(ctx)
+}
+
+func (p *tailSamplingProcessor) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(max(p.config.Window/4, 100*time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			p.mu.Lock()
+			var kept []sdktrace.ReadOnlySpan
+			for len(p.order) > 0 && now.Sub(p.order[0].start) >= p.config.Window {
+				kept = append(kept, p.decideOldest()...)
+			}
+
+			// the decisions are remembered for the spans ending late, e.g. in background tasks
+			for id, d := range p.decided {
+				if now.Sub(d.at) >= 2*p.config.Window {
+					delete(p.decided, id)
+				}
+			}
+			p.mu.Unlock()
+
+			p.export(kept)
+		}
+	}
+}
+
+// flush decides every buffered trace, without waiting for the end of their window
+func (p *tailSamplingProcessor) flush() {
+	p.mu.Lock()
+	var kept []sdktrace.ReadOnlySpan
+	for len(p.order) > 0 {
+		kept = append(kept, p.decideOldest()...)
+	}
+	p.mu.Unlock()
+
+	p.export(kept)
+}
+
+func (p *tailSamplingProcessor) export(spans []sdktrace.ReadOnlySpan) {
+	for _, span := range spans {
+		p.next.OnEnd(span)
+	}
+}
+
+// decideOldest decides the oldest pending trace and returns its spans when it is kept, p.mu must be held.
+func (p *tailSamplingProcessor) decideOldest() []sdktrace.ReadOnlySpan {
+	t := p.order[0]
+	p.order[0] = nil
+	p.order = p.order[1:]
+	delete(p.pending, t.id)
+	p.buffered -= len(t.spans)
+
+	reason := p.reason(t.spans)
+	keep := reason != ""
+	if !keep {
+		reason = "other"
+	}
+
+	result := decisionDropped
+	if keep {
+		result = decisionSampled
+	}
+	p.decisions[decisionKey{result, reason}]++
+	p.decided[t.id] = decision{keep: keep, at: time.Now()}
+
+	if !keep {
+		return nil
+	}
+	return t.spans
+}
+
+// reason returns why the trace is kept, empty when it is dropped
+func (p *tailSamplingProcessor) reason(spans []sdktrace.ReadOnlySpan) string {
+	for _, span := range spans {
+		if span.Status().Code == codes.Error {
+			return "error"
+		}
+	}
+
+	if p.config.Latency > 0 {
+		for _, span := range spans {
+			if span.EndTime().Sub(span.StartTime()) > p.config.Latency {
+				return "latency"
+			}
+		}
+	}
+
+	for _, span := range spans {
+		for _, predicate := range p.config.Predicates {
+			if predicate(span) {
+				return "predicate"
+			}
+		}
+	}
+
+	if sampledByRatio(spans[0].SpanContext().TraceID(), p.config.Probability) {
+		return "probabilistic"
+	}
+
+	return ""
+}
+
+// sampledByRatio keeps the fraction of the trace ids the way sdktrace.TraceIDRatioBased does,
+// so every service keeps the same traces.
+func sampledByRatio(id trace.TraceID, fraction float64) bool {
+	if fraction >= 1 {
+		return true
+	}
+	bound := uint64(max(fraction, 0) * (1 << 63))
+	return binary.BigEndian.Uint64(id[8:16])>>1 < bound
+}
+
+// registerCounters reports the decisions and the buffered spans.
+func (p *tailSamplingProcessor) registerCounters(meter metric.Meter) error {
+	decisions, err := meter.Int64ObservableCounter(
+		"otel.tail_sampling.decisions",
+		metric.WithDescription("traces sampled or dropped by the tail sampling, by reason"),
+		metric.WithUnit("{trace}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	evicted, err := meter.Int64ObservableCounter(
+		"otel.tail_sampling.evicted",
+		metric.WithDescription("traces decided before the end of their window to bound the memory"),
+		metric.WithUnit("{trace}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	buffered, err := meter.Int64ObservableGauge(
+		"otel.tail_sampling.buffered_spans",
+		metric.WithDescription("spans waiting for the decision on their trace"),
+		metric.WithUnit("{span}"),
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(
+		func(_ context.Context, observer metric.Observer) error {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+
+			for key, count := range p.decisions {
+				observer.ObserveInt64(
+					decisions, count,
+					metric.WithAttributes(attribute.String("decision", key.decision), attribute.String("reason", key.reason)),
+				)
+			}
+			observer.ObserveInt64(evicted, p.evicted)
+			observer.ObserveInt64(buffered, int64(p.buffered))
+			return nil
+		},
+		decisions, evicted, buffered,
+	)
+
+	return err
+}
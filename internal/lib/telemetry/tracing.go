@@ -2,37 +2,68 @@ package telemetry
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
-	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/encoding/gzip"
 )
 
-func configureTracing(ctx context.Context, resource *resource.Resource) (func(context.Context) error, error) {
-	exporter, err := newOTLPTraceExporter(ctx)
+func configureTracing(ctx context.Context, cfg Config, resource *resource.Resource) (func(context.Context) error, error) {
+	propagator, err := newPropagator(propagatorNames(cfg.propagators))
 	if err != nil {
-		return nil, fmt.Errorf("failed to create exporter: %w", err)
+		return nil, err
 	}
 
-	provider := sdktrace.NewTracerProvider(
+	opts := []sdktrace.TracerProviderOption{
 		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
 		sdktrace.WithResource(resource),
-		sdktrace.WithBatcher(exporter),
-	)
+	}
 
-	otel.SetTextMapPropagator(
-		propagation.NewCompositeTextMapPropagator(
-			propagation.TraceContext{},
-			propagation.Baggage{},
-		),
-	)
+	// the span metrics count every span, whatever the tail sampling decides or when no exporter is configured
+	if cfg.spanMetrics {
+		spanMetrics, err := newSpanMetricsProcessor(otel.Meter("otel-playground/telemetry"), resource)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the span metrics: %w", err)
+		}
+		opts = append(opts, sdktrace.WithSpanProcessor(spanMetrics))
+	}
+	if cfg.serviceGraph != nil {
+		serviceGraph, err := newServiceGraphProcessor(otel.Meter("otel-playground/telemetry"), resource, cfg.serviceGraph)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the service graph metrics: %w", err)
+		}
+		opts = append(opts, sdktrace.WithSpanProcessor(serviceGraph))
+	}
+
+	exporter, err := newSpanExporter(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the exporters: %w", err)
+	}
+
+	if exporter != nil {
+		var processor sdktrace.SpanProcessor = sdktrace.NewBatchSpanProcessor(exporter)
+		if cfg.tailSampling.enabled() {
+			tailSampling := newTailSamplingProcessor(processor, cfg.tailSampling)
+			if err := tailSampling.registerCounters(otel.Meter("otel-playground/telemetry")); err != nil {
+				_ = tailSampling.Shutdown(ctx)
+				return nil, fmt.Errorf("failed to register the tail sampling metrics: %w", err)
+			}
+			processor = tailSampling
+		}
+		opts = append(opts, sdktrace.WithSpanProcessor(newAttributeProcessor(processor, cfg, resource)))
+	}
+
+	provider := sdktrace.NewTracerProvider(opts...)
+
+	otel.SetTextMapPropagator(propagator)
 
 	otel.SetTracerProvider(provider)
 
@@ -41,12 +72,17 @@ func configureTracing(ctx context.Context, resource *resource.Resource) (func(co
 	}, nil
 }
 
-func newOTLPTraceExporter(ctx context.Context) (*otlptrace.Exporter, error) {
+func newOTLPTraceExporter(ctx context.Context, tlsConfig *tls.Config) (*otlptrace.Exporter, error) {
+	security := otlptracegrpc.WithInsecure()
+	if tlsConfig != nil {
+		security = otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsConfig))
+	}
+
 	return otlptrace.New(
 		ctx,
 		otlptracegrpc.NewClient(
 			otlptracegrpc.WithCompressor(gzip.Name),
-			otlptracegrpc.WithInsecure(),
+			security,
 		),
 	)
 }
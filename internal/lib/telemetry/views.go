@@ -0,0 +1,181 @@
+package telemetry
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// View customizes how the measurements of an instrument are aggregated and exported.
+type View struct {
+	// Instrument is the name of the instrument the view applies to
+	Instrument string
+	// Name renames the exported metric, empty keeps the instrument name
+	Name string
+	// DropAttributes are removed from the measurements before they are aggregated
+	DropAttributes []attribute.Key
+	// Buckets are the histogram boundaries, a view with buckets only matches histograms
+	Buckets []float64
+}
+
+// overflowAttribute is the attribute the SDK exports the measurements with once an instrument reached its cardinality limit.
+var overflowAttribute = attribute.Bool("otel.metric.overflow", true)
+
+const defaultCardinalityLimit = 2000
+
+// defaultViews are applied before the configured ones, the otelhttp durations are in seconds.
+var defaultViews = []View{
+	{
+		Instrument: "http.server.request.duration",
+		Buckets:    []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	},
+}
+
+// newViews maps the default and the configured views to the SDK ones, a later view for an instrument
+// replaces the earlier one since the SDK would export a stream per matching view.
+func newViews(views []View) []sdkmetric.View {
+	var names []string
+	byName := map[string]View{}
+	for _, view := range append(append([]View{}, defaultViews...), views...) {
+		if _, ok := byName[view.Instrument]; !ok {
+			names = append(names, view.Instrument)
+		}
+		byName[view.Instrument] = view
+	}
+
+	sdkViews := make([]sdkmetric.View, 0, len(names))
+	for _, name := range names {
+		view := byName[name]
+
+		criteria := sdkmetric.Instrument{Name: view.Instrument}
+		stream := sdkmetric.Stream{Name: view.Name}
+
+		if len(view.DropAttributes) > 0 {
+			stream.AttributeFilter = attribute.NewDenyKeysFilter(view.DropAttributes...)
+		}
+
+		if len(view.Buckets) > 0 {
+			criteria.Kind = sdkmetric.InstrumentKindHistogram
+			stream.Aggregation = sdkmetric.AggregationExplicitBucketHistogram{Boundaries: view.Buckets}
+		}
+
+		sdkViews = append(sdkViews, sdkmetric.NewView(criteria, stream))
+	}
+
+	return sdkViews
+}
+
+// overflowExporter looks for the overflow series in the exported metrics, the SDK folds the measurements
+// into them silently.
+type overflowExporter struct {
+	sdkmetric.Exporter
+
+	mu         sync.Mutex
+	overflowed []string
+}
+
+func newOverflowExporter(exporter sdkmetric.Exporter) *overflowExporter {
+	return &overflowExporter{Exporter: exporter}
+}
+
+func (e *overflowExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	var overflowed []string
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if hasOverflow(m.Data) {
+				overflowed = append(overflowed, m.Name)
+			}
+		}
+	}
+
+	e.mu.Lock()
+	e.overflowed = overflowed
+	e.mu.Unlock()
+
+	return e.Exporter.Export(ctx, rm)
+}
+
+// registerGauge reports 1 as the otel.cardinality_limit_reached gauge for each instrument with an overflow series
+// in the last export. With the cumulative temporality an instrument keeps it until the process restarts.
+func (e *overflowExporter) registerGauge(meter metric.Meter) error {
+	_, err := meter.Int64ObservableGauge(
+		"otel.cardinality_limit_reached",
+		metric.WithDescription("1 for the instruments whose last export had measurements beyond their cardinality limit"),
+		metric.WithInt64Callback(
+			func(_ context.Context, observer metric.Int64Observer) error {
+				for _, name := range e.snapshot() {
+					observer.Observe(1, metric.WithAttributes(attribute.String("instrument", name)))
+				}
+				return nil
+			},
+		),
+	)
+
+	return err
+}
+
+func (e *overflowExporter) snapshot() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.overflowed
+}
+
+// discardExporter drops the metrics, it lets the overflow exporter look for the overflow series
+// when Prometheus is the only reader.
+type discardExporter struct{}
+
+func (discardExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(kind)
+}
+
+func (discardExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+func (discardExporter) Export(context.Context, *metricdata.ResourceMetrics) error { return nil }
+
+func (discardExporter) ForceFlush(context.Context) error { return nil }
+
+func (discardExporter) Shutdown(context.Context) error { return nil }
+
+func hasOverflow(data metricdata.Aggregation) bool {
+	switch d := data.(type) {
+	case metricdata.Sum[int64]:
+		return overflowPoints(d.DataPoints)
+	case metricdata.Sum[float64]:
+		return overflowPoints(d.DataPoints)
+	case metricdata.Gauge[int64]:
+		return overflowPoints(d.DataPoints)
+	case metricdata.Gauge[float64]:
+		return overflowPoints(d.DataPoints)
+	case metricdata.Histogram[int64]:
+		return overflowHistogramPoints(d.DataPoints)
+	case metricdata.Histogram[float64]:
+		return overflowHistogramPoints(d.DataPoints)
+	}
+
+	return false
+}
+
+func overflowPoints[N int64 | float64](points []metricdata.DataPoint[N]) bool {
+	for _, point := range points {
+		if point.Attributes.HasValue(overflowAttribute.Key) {
+			return true
+		}
+	}
+	return false
+}
+
+func overflowHistogramPoints[N int64 | float64](points []metricdata.HistogramDataPoint[N]) bool {
+	for _, point := range points {
+		if point.Attributes.HasValue(overflowAttribute.Key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,445 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+
+	"github.com/username/otel-playground/internal/lib/otlpjson"
+)
+
+// writeCloser is the output of the stdout and file exporters, stdout isn't closed
+type writeCloser struct {
+	io.Writer
+	close func() error
+}
+
+// writerSpanExporter writes the spans to stdout or to a file, each batch with a single write so that
+// the rotation of the file never splits a line
+type writerSpanExporter struct {
+	format    string
+	mu        sync.Mutex
+	out       writeCloser
+	closeOnce sync.Once
+}
+
+func newWriterSpanExporter(cfg ExporterConfig, service string) (*writerSpanExporter, error) {
+	format, err := cfg.format()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cfg.output(service, SignalTraces)
+	if err != nil {
+		return nil, err
+	}
+
+	return &writerSpanExporter{format: format, out: out}, nil
+}
+
+func (e *writerSpanExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	var b bytes.Buffer
+	if e.format == FormatJSONL {
+		line, err := json.Marshal(tracesData(spans))
+		if err != nil {
+			return fmt.Errorf("failed to encode the spans: %w", err)
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	} else {
+		for _, span := range spans {
+			writePrettySpan(&b, span)
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	_, err := e.out.Write(b.Bytes())
+	return err
+}
+
+func (e *writerSpanExporter) Shutdown(context.Context) (err error) {
+	e.closeOnce.Do(func() { err = e.out.close() })
+	return err
+}
+
+// writerMetricExporter writes the data points to stdout or to a file, each export with a single write
+type writerMetricExporter struct {
+	format      string
+	temporality sdkmetric.TemporalitySelector
+	mu          sync.Mutex
+	out         writeCloser
+	closeOnce   sync.Once
+}
+
+func newWriterMetricExporter(
+	cfg ExporterConfig, service string, temporality sdkmetric.TemporalitySelector,
+) (*writerMetricExporter, error) {
+	format, err := cfg.format()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := cfg.output(service, SignalMetrics)
+	if err != nil {
+		return nil, err
+	}
+
+	if temporality == nil {
+		temporality = sdkmetric.DefaultTemporalitySelector
+	}
+
+	return &writerMetricExporter{format: format, temporality: temporality, out: out}, nil
+}
+
+func (e *writerMetricExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	return e.temporality(kind)
+}
+
+func (e *writerMetricExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+func (e *writerMetricExporter) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	var b bytes.Buffer
+	for _, p := range metricPoints(rm) {
+		if e.format == FormatJSONL {
+			line, err := json.Marshal(p)
+			if err != nil {
+				return fmt.Errorf("failed to encode the data points: %w", err)
+			}
+			b.Write(line)
+			b.WriteByte('\n')
+		} else {
+			writePrettyPoint(&b, p)
+		}
+	}
+
+	if b.Len() == 0 {
+		return nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	_, err := e.out.Write(b.Bytes())
+	return err
+}
+
+func (e *writerMetricExporter) ForceFlush(context.Context) error {
+	return nil
+}
+
+func (e *writerMetricExporter) Shutdown(context.Context) (err error) {
+	e.closeOnce.Do(func() { err = e.out.close() })
+	return err
+}
+
+type scopeKey struct {
+	resource      int
+	name, version string
+}
+
+// tracesData groups the spans by resource and instrumentation scope, as the OTLP exporters do
+func tracesData(spans []sdktrace.ReadOnlySpan) otlpjson.TracesData {
+	var data otlpjson.TracesData
+	resources := map[*resource.Resource]int{}
+	scopes := map[scopeKey]int{}
+
+	for _, span := range spans {
+		r, ok := resources[span.Resource()]
+		if !ok {
+			r = len(data.ResourceSpans)
+			resources[span.Resource()] = r
+			data.ResourceSpans = append(
+				data.ResourceSpans, otlpjson.ResourceSpans{
+					Resource:  otlpjson.Resource{Attributes: keyValues(span.Resource().Attributes())},
+					SchemaURL: span.Resource().SchemaURL(),
+				},
+			)
+		}
+		rs := &data.ResourceSpans[r]
+
+		scope := span.InstrumentationScope()
+		key := scopeKey{r, scope.Name, scope.Version}
+		s, ok := scopes[key]
+		if !ok {
+			s = len(rs.ScopeSpans)
+			scopes[key] = s
+			rs.ScopeSpans = append(
+				rs.ScopeSpans, otlpjson.ScopeSpans{Scope: otlpjson.Scope{Name: scope.Name, Version: scope.Version}},
+			)
+		}
+
+		rs.ScopeSpans[s].Spans = append(rs.ScopeSpans[s].Spans, spanData(span))
+	}
+
+	return data
+}
+
+func spanData(span sdktrace.ReadOnlySpan) otlpjson.SpanData {
+	var parent string
+	if span.Parent().SpanID().IsValid() {
+		parent = span.Parent().SpanID().String()
+	}
+
+	var events []otlpjson.Event
+	for _, event := range span.Events() {
+		events = append(
+			events, otlpjson.Event{
+				TimeUnixNano: unixNano(event.Time),
+				Name:         event.Name,
+				Attributes:   keyValues(event.Attributes),
+			},
+		)
+	}
+
+	return otlpjson.SpanData{
+		TraceID:           span.SpanContext().TraceID().String(),
+		SpanID:            span.SpanContext().SpanID().String(),
+		ParentSpanID:      parent,
+		Name:              span.Name(),
+		Kind:              int(span.SpanKind()),
+		StartTimeUnixNano: unixNano(span.StartTime()),
+		EndTimeUnixNano:   unixNano(span.EndTime()),
+		Attributes:        keyValues(span.Attributes()),
+		Events:            events,
+		Status:            otlpjson.Status{Code: statusCode(span.Status().Code), Message: span.Status().Description},
+	}
+}
+
+// statusCode converts the status code, the API and OTLP number Ok and Error differently
+func statusCode(code codes.Code) int {
+	switch code {
+	case codes.Ok:
+		return otlpjson.StatusOk
+	case codes.Error:
+		return otlpjson.StatusError
+	default:
+		return 0
+	}
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func keyValues(attrs []attribute.KeyValue) []otlpjson.KeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	result := make([]otlpjson.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		result = append(result, otlpjson.KeyValue{Key: string(kv.Key), Value: anyValue(kv.Value)})
+	}
+	return result
+}
+
+// anyValue converts the value, the slices are kept as their json encoding in a string like the otlpsink does
+func anyValue(v attribute.Value) otlpjson.AnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		b := v.AsBool()
+		return otlpjson.AnyValue{BoolValue: &b}
+	case attribute.INT64:
+		i := strconv.FormatInt(v.AsInt64(), 10)
+		return otlpjson.AnyValue{IntValue: &i}
+	case attribute.FLOAT64:
+		f := v.AsFloat64()
+		return otlpjson.AnyValue{DoubleValue: &f}
+	default:
+		s := v.Emit()
+		return otlpjson.AnyValue{StringValue: &s}
+	}
+}
+
+// writePrettySpan writes the span on a line, followed by a line per event
+func writePrettySpan(b *bytes.Buffer, span sdktrace.ReadOnlySpan) {
+	service, _ := span.Resource().Set().Value(semconv.ServiceNameKey)
+
+	status := strings.ToLower(span.Status().Code.String())
+	if span.Status().Description != "" {
+		status += ": " + span.Status().Description
+	}
+
+	fmt.Fprintf(
+		b, "%s %s %s %q %s %s trace_id=%s span_id=%s",
+		span.StartTime().Format(time.StampMicro), service.Emit(), span.SpanKind(), span.Name(),
+		span.EndTime().Sub(span.StartTime()), status,
+		span.SpanContext().TraceID(), span.SpanContext().SpanID(),
+	)
+	if span.Parent().SpanID().IsValid() {
+		fmt.Fprintf(b, " parent_span_id=%s", span.Parent().SpanID())
+	}
+	writePrettyAttributes(b, span.Attributes())
+	b.WriteByte('\n')
+
+	for _, event := range span.Events() {
+		fmt.Fprintf(b, "    event %q +%s", event.Name, event.Time.Sub(span.StartTime()))
+		writePrettyAttributes(b, event.Attributes)
+		b.WriteByte('\n')
+	}
+}
+
+func writePrettyAttributes(b *bytes.Buffer, attrs []attribute.KeyValue) {
+	for _, kv := range attrs {
+		fmt.Fprintf(b, " %s=%q", kv.Key, kv.Value.Emit())
+	}
+}
+
+// writePrettyPoint writes the data point on a line, like the Prometheus text format
+func writePrettyPoint(b *bytes.Buffer, p otlpjson.Point) {
+	keys := make([]string, 0, len(p.Attributes))
+	for key := range p.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	labels := make([]string, 0, len(keys))
+	for _, key := range keys {
+		labels = append(labels, fmt.Sprintf("%s=%q", key, p.Attributes[key]))
+	}
+
+	fmt.Fprintf(b, "%s %s %s{%s}", p.Time.Format(time.StampMicro), p.Service, p.Name, strings.Join(labels, ","))
+	switch p.Type {
+	case "gauge", "sum":
+		fmt.Fprintf(b, " %s", strconv.FormatFloat(p.Value, 'g', -1, 64))
+	default:
+		fmt.Fprintf(b, " count=%d sum=%s", p.Count, strconv.FormatFloat(p.Sum, 'g', -1, 64))
+		writePrettyBuckets(b, p)
+	}
+	if p.Unit != "" {
+		fmt.Fprintf(b, " %s", p.Unit)
+	}
+	b.WriteByte('\n')
+}
+
+// writePrettyBuckets writes the count of each bucket after its upper bound, e.g. buckets=0.1:3,1:7,+Inf:2
+func writePrettyBuckets(b *bytes.Buffer, p otlpjson.Point) {
+	if len(p.BucketCounts) == 0 {
+		return
+	}
+
+	buckets := make([]string, 0, len(p.BucketCounts))
+	for i, count := range p.BucketCounts {
+		bound := "+Inf"
+		if i < len(p.Bounds) {
+			bound = strconv.FormatFloat(p.Bounds[i], 'g', -1, 64)
+		}
+		buckets = append(buckets, fmt.Sprintf("%s:%d", bound, count))
+	}
+	fmt.Fprintf(b, " buckets=%s", strings.Join(buckets, ","))
+}
+
+type newPointFunc func(typ string, t time.Time, attrs attribute.Set) otlpjson.Point
+
+// metricPoints flattens the data points of the metrics, like the otlpsink does with the received ones
+func metricPoints(rm *metricdata.ResourceMetrics) []otlpjson.Point {
+	service, _ := rm.Resource.Set().Value(semconv.ServiceNameKey)
+
+	var points []otlpjson.Point
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			newPoint := func(typ string, t time.Time, attrs attribute.Set) otlpjson.Point {
+				return otlpjson.Point{
+					Time:       t,
+					Service:    service.Emit(),
+					Name:       m.Name,
+					Unit:       m.Unit,
+					Type:       typ,
+					Attributes: attributesMap(attrs),
+				}
+			}
+
+			switch data := m.Data.(type) {
+			case metricdata.Gauge[int64]:
+				points = appendNumberPoints(points, "gauge", data.DataPoints, newPoint)
+			case metricdata.Gauge[float64]:
+				points = appendNumberPoints(points, "gauge", data.DataPoints, newPoint)
+			case metricdata.Sum[int64]:
+				points = appendNumberPoints(points, "sum", data.DataPoints, newPoint)
+			case metricdata.Sum[float64]:
+				points = appendNumberPoints(points, "sum", data.DataPoints, newPoint)
+			case metricdata.Histogram[int64]:
+				points = appendHistogramPoints(points, data.DataPoints, newPoint)
+			case metricdata.Histogram[float64]:
+				points = appendHistogramPoints(points, data.DataPoints, newPoint)
+			case metricdata.ExponentialHistogram[int64]:
+				points = appendExponentialHistogramPoints(points, data.DataPoints, newPoint)
+			case metricdata.ExponentialHistogram[float64]:
+				points = appendExponentialHistogramPoints(points, data.DataPoints, newPoint)
+			case metricdata.Summary:
+				for _, dp := range data.DataPoints {
+					p := newPoint("summary", dp.Time, dp.Attributes)
+					p.Count, p.Sum = dp.Count, dp.Sum
+					points = append(points, p)
+				}
+			}
+		}
+	}
+
+	return points
+}
+
+func appendNumberPoints[N int64 | float64](
+	points []otlpjson.Point, typ string, dps []metricdata.DataPoint[N], newPoint newPointFunc,
+) []otlpjson.Point {
+	for _, dp := range dps {
+		p := newPoint(typ, dp.Time, dp.Attributes)
+		p.Value = float64(dp.Value)
+		points = append(points, p)
+	}
+	return points
+}
+
+func appendHistogramPoints[N int64 | float64](
+	points []otlpjson.Point, dps []metricdata.HistogramDataPoint[N], newPoint newPointFunc,
+) []otlpjson.Point {
+	for _, dp := range dps {
+		p := newPoint("histogram", dp.Time, dp.Attributes)
+		p.Count, p.Sum = dp.Count, float64(dp.Sum)
+		p.Bounds, p.BucketCounts = dp.Bounds, dp.BucketCounts
+		points = append(points, p)
+	}
+	return points
+}
+
+func appendExponentialHistogramPoints[N int64 | float64](
+	points []otlpjson.Point, dps []metricdata.ExponentialHistogramDataPoint[N], newPoint newPointFunc,
+) []otlpjson.Point {
+	for _, dp := range dps {
+		p := newPoint("exponential_histogram", dp.Time, dp.Attributes)
+		p.Count, p.Sum = dp.Count, float64(dp.Sum)
+		points = append(points, p)
+	}
+	return points
+}
+
+func attributesMap(set attribute.Set) map[string]string {
+	if set.Len() == 0 {
+		return nil
+	}
+
+	attrs := make(map[string]string, set.Len())
+	for _, kv := range set.ToSlice() {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	return attrs
+}
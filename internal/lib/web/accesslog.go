@@ -0,0 +1,127 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/username/otel-playground/internal/lib/redact"
+)
+
+var accessLogger = log.New(os.Stdout, "", 0)
+
+type accessLogEntry struct {
+	Time       string  `json:"time"`
+	RequestID  string  `json:"request_id"`
+	TraceID    string  `json:"trace_id,omitempty"`
+	Method     string  `json:"method"`
+	Route      string  `json:"route"`
+	Path       string  `json:"path"`
+	Status     int     `json:"status"`
+	Bytes      int64   `json:"bytes"`
+	DurationMS float64 `json:"duration_ms"`
+	RemoteAddr string  `json:"remote_addr"`
+	// Response is the redacted json response body, logged with WithResponseBodies
+	Response json.RawMessage `json:"response,omitempty"`
+}
+
+// maxLoggedResponse is the size of the response bodies kept for the access log
+const maxLoggedResponse = 4096
+
+type (
+	accessLogConfig struct {
+		responses bool
+		redactor  *redact.Redactor
+	}
+
+	AccessLogOption func(*accessLogConfig)
+)
+
+// WithResponseBodies logs the json response bodies, up to 4KiB, with the values matching the redactor rules
+// redacted; a nil redactor logs them as is.
+func WithResponseBodies(redactor *redact.Redactor) AccessLogOption {
+	return func(c *accessLogConfig) {
+		c.responses = true
+		c.redactor = redactor
+	}
+}
+
+// bodyRecorder keeps the beginning of the response body
+type bodyRecorder struct {
+	*responseWriterInterceptor
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	if remaining := maxLoggedResponse - w.body.Len(); remaining > 0 {
+		w.body.Write(b[:min(len(b), remaining)])
+	}
+	return w.responseWriterInterceptor.Write(b)
+}
+
+// NewAccessLogHandler writes one json line per request, skipping the filtered urls.
+func NewAccessLogHandler(next http.Handler, filters FilterURLs, opts ...AccessLogOption) http.Handler {
+	var cfg accessLogConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if !filters.Use(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			wi := &responseWriterInterceptor{
+				statusCode:     http.StatusOK,
+				ResponseWriter: w,
+			}
+			var recorder *bodyRecorder
+			if cfg.responses {
+				recorder = &bodyRecorder{responseWriterInterceptor: wi}
+				next.ServeHTTP(recorder, r)
+			} else {
+				next.ServeHTTP(wi, r)
+			}
+
+			entry := accessLogEntry{
+				Time:       start.UTC().Format(time.RFC3339Nano),
+				RequestID:  RequestIDFromContext(r.Context()),
+				Method:     r.Method,
+				Route:      RouteFromContext(r.Context()),
+				Path:       r.URL.Path,
+				Status:     wi.statusCode,
+				Bytes:      wi.written,
+				DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+				RemoteAddr: r.RemoteAddr,
+			}
+
+			if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+				entry.TraceID = sc.TraceID().String()
+			}
+
+			if recorder != nil && json.Valid(recorder.body.Bytes()) {
+				response, err := cfg.redactor.JSON(recorder.body.Bytes())
+				if err != nil {
+					log.Printf("failed to redact the response: %v\n", err)
+				}
+				entry.Response = response
+			}
+
+			line, err := json.Marshal(entry)
+			if err != nil {
+				log.Printf("failed to encode access log: %v\n", err)
+				return
+			}
+
+			accessLogger.Println(string(line))
+		},
+	)
+}
@@ -0,0 +1,91 @@
+package web
+
+import (
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// newAdminServer serves the runtime introspection endpoints, it isn't instrumented so profiling
+// and debugging don't show up in the traces and metrics.
+func newAdminServer(cfg serverConfig) *http.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	mux.HandleFunc(
+		"/debug/goroutines", func(w http.ResponseWriter, r *http.Request) {
+			r.URL.RawQuery = "debug=2"
+			pprof.Handler("goroutine").ServeHTTP(w, r)
+		},
+	)
+
+	mux.HandleFunc(
+		"/debug/config", func(w http.ResponseWriter, r *http.Request) {
+			WriteJSON(w, http.StatusOK, effectiveConfig(cfg))
+		},
+	)
+
+	mux.HandleFunc(
+		"/debug/telemetry", func(w http.ResponseWriter, r *http.Request) {
+			if cfg.telemetry == nil {
+				WriteJSON(w, http.StatusNotFound, Envelope{"error": "telemetry is not configured"})
+				return
+			}
+			WriteJSON(w, http.StatusOK, cfg.telemetry.Info())
+		},
+	)
+
+	mux.HandleFunc(
+		"/debug/servicegraph", func(w http.ResponseWriter, r *http.Request) {
+			if cfg.telemetry == nil || cfg.telemetry.ServiceGraph() == nil {
+				WriteJSON(w, http.StatusNotFound, Envelope{"error": "the service graph is not enabled"})
+				return
+			}
+			cfg.telemetry.ServiceGraph().ServeHTTP(w, r)
+		},
+	)
+
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.adminPort),
+		Handler:           mux,
+		ReadHeaderTimeout: cfg.readHeaderTimeout,
+		IdleTimeout:       cfg.idleTimeout,
+	}
+}
+
+func effectiveConfig(cfg serverConfig) Envelope {
+	return Envelope{
+		"server": Envelope{
+			"name":                cfg.name,
+			"port":                cfg.port,
+			"admin_port":          cfg.adminPort,
+			"filters":             cfg.filters,
+			"tls":                 cfg.tlsConfig != nil,
+			"read_timeout":        cfg.readTimeout.String(),
+			"read_header_timeout": cfg.readHeaderTimeout.String(),
+			"write_timeout":       cfg.writeTimeout.String(),
+			"idle_timeout":        cfg.idleTimeout.String(),
+			"max_header_bytes":    cfg.maxHeaderBytes,
+			"drain_delay":         cfg.drainDelay.String(),
+			"shutdown_timeout":    cfg.shutdownTimeout.String(),
+			"flush_timeout":       cfg.flushTimeout.String(),
+			"ready":               IsReady(),
+		},
+		"runtime": Envelope{
+			"go_version": runtime.Version(),
+			"gomaxprocs": runtime.GOMAXPROCS(0),
+			"num_cpu":    runtime.NumCPU(),
+			"goroutines": runtime.NumGoroutine(),
+			"time":       time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+}
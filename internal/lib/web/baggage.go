@@ -0,0 +1,146 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/username/otel-playground/internal/lib/environment"
+)
+
+// baggageAttributePrefix prefixes the baggage keys promoted to attributes, e.g. baggage.username
+const baggageAttributePrefix = "baggage."
+
+// BaggagePolicy controls the baggage accepted from the callers and what is done with it.
+type BaggagePolicy struct {
+	// Headers maps request headers to the baggage keys they are copied to, e.g. X-User to username
+	Headers map[string]string
+	// Promote lists the baggage keys added as attributes to the server span and the http metrics,
+	// their values should come from a small set or they will exhaust the metrics cardinality limit
+	Promote []string
+	// MaxEntries is the maximum number of baggage entries, 0 means no limit
+	MaxEntries int
+	// MaxBytes is the maximum size of the encoded baggage, 0 means no limit
+	MaxBytes int
+}
+
+// DefaultBaggagePolicy accepts small baggage and promotes nothing
+var DefaultBaggagePolicy = BaggagePolicy{MaxEntries: 16, MaxBytes: 1024}
+
+// BaggagePolicyFromEnv reads the policy from the BAGGAGE_* environment variables:
+// BAGGAGE_HEADERS as "X-User=username,X-Tenant=tenant", BAGGAGE_PROMOTE as "username,tenant",
+// BAGGAGE_MAX_ENTRIES and BAGGAGE_MAX_BYTES.
+func BaggagePolicyFromEnv() BaggagePolicy {
+	policy := BaggagePolicy{
+		Headers:    map[string]string{},
+		MaxEntries: environment.Get("BAGGAGE_MAX_ENTRIES", DefaultBaggagePolicy.MaxEntries),
+		MaxBytes:   environment.Get("BAGGAGE_MAX_BYTES", DefaultBaggagePolicy.MaxBytes),
+	}
+
+	for _, mapping := range splitList(environment.Get("BAGGAGE_HEADERS", "")) {
+		if header, key, ok := strings.Cut(mapping, "="); ok {
+			policy.Headers[strings.TrimSpace(header)] = strings.TrimSpace(key)
+		}
+	}
+	policy.Promote = splitList(environment.Get("BAGGAGE_PROMOTE", ""))
+
+	return policy
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// NewBaggageHandler enforces the policy on the incoming baggage: baggage over the limits is dropped as a whole,
+// then the configured headers are added and the allowed keys promoted to attributes.
+func NewBaggageHandler(next http.Handler, policy BaggagePolicy) http.Handler {
+	// the headers are sorted so the entries over the limits are always the same ones
+	headers := make([]string, 0, len(policy.Headers))
+	for header := range policy.Headers {
+		headers = append(headers, header)
+	}
+	sort.Strings(headers)
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			span := trace.SpanFromContext(ctx)
+
+			bag := baggage.FromContext(ctx)
+			if reason := policy.exceeded(bag); reason != "" {
+				span.AddEvent(
+					"baggage_dropped",
+					trace.WithAttributes(attribute.String("reason", reason), attribute.Int("baggage.size", len(bag.String()))),
+				)
+				bag = baggage.Baggage{}
+			}
+
+			for _, header := range headers {
+				value := r.Header.Get(header)
+				if value == "" {
+					continue
+				}
+
+				member, err := baggage.NewMemberRaw(policy.Headers[header], value)
+				if err != nil {
+					continue
+				}
+				if withMember, err := bag.SetMember(member); err == nil && policy.exceeded(withMember) == "" {
+					bag = withMember
+				}
+			}
+
+			var attrs []attribute.KeyValue
+			for _, key := range policy.Promote {
+				if member := bag.Member(key); member.Key() != "" {
+					attrs = append(attrs, attribute.String(baggageAttributePrefix+key, member.Value()))
+				}
+			}
+
+			if len(attrs) > 0 {
+				span.SetAttributes(attrs...)
+				if labeler, ok := otelhttp.LabelerFromContext(ctx); ok {
+					labeler.Add(attrs...)
+				}
+				if info := requestInfoFromContext(ctx); info != nil {
+					info.baggage = attrs
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(baggage.ContextWithBaggage(ctx, bag)))
+		},
+	)
+}
+
+// exceeded returns which limit the baggage is over, empty when it is within the limits
+func (p BaggagePolicy) exceeded(bag baggage.Baggage) string {
+	switch {
+	case p.MaxEntries > 0 && bag.Len() > p.MaxEntries:
+		return "max_entries"
+	case p.MaxBytes > 0 && len(bag.String()) > p.MaxBytes:
+		return "max_bytes"
+	default:
+		return ""
+	}
+}
+
+// BaggageAttributesFromContext returns the baggage promoted to attributes for the incoming request,
+// to be added to the metrics recorded by the handlers
+func BaggageAttributesFromContext(ctx context.Context) []attribute.KeyValue {
+	if info := requestInfoFromContext(ctx); info != nil {
+		return info.baggage
+	}
+	return nil
+}
@@ -1,24 +1,35 @@
 package web
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/username/otel-playground/internal/lib/collections"
 	libjson "github.com/username/otel-playground/internal/lib/json"
 	"github.com/username/otel-playground/internal/lib/telemetry"
 )
 
-func NewTransport() *otelhttp.Transport {
-	return otelhttp.NewTransport(
-		nil,
-		otelhttp.WithPropagators(otel.GetTextMapPropagator()),
-	)
-}
+const (
+	JSONContentType   = "application/json"
+	NDJSONContentType = "application/x-ndjson"
+)
+
+var ErrUnexpectedContentType = errors.New("unexpected response content type")
 
 var successfulStatuses = []int{
 	http.StatusOK,
@@ -28,11 +39,250 @@ var successfulStatuses = []int{
 	http.StatusNoContent,
 }
 
-type ResponseError http.Response
+// NewTransport wraps the base transport, http.DefaultTransport when nil, to create client spans
+// and propagate the trace context and the request id.
+func NewTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return otelhttp.NewTransport(
+		requestIDTransport{base: base},
+		otelhttp.WithPropagators(otel.GetTextMapPropagator()),
+	)
+}
+
+type requestIDTransport struct {
+	base http.RoundTripper
+}
+
+func (t requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if id := RequestIDFromContext(req.Context()); id != "" && req.Header.Get(RequestIDHeader) == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set(RequestIDHeader, id)
+	}
+	return t.base.RoundTrip(req)
+}
+
+type (
+	// Client is an instrumented http client, all the calls made to the other services should go through it.
+	Client struct {
+		http    *http.Client
+		baseURL string
+		header  http.Header
+	}
+
+	ClientOption func(*Client)
+)
+
+// DefaultClient is the client used by GetJSON.
+var DefaultClient = NewClient()
+
+func NewClient(opts ...ClientOption) *Client {
+	c := &Client{
+		http:   &http.Client{Transport: NewTransport(nil)},
+		header: http.Header{},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// WithBaseURL configures the url prepended to relative request urls
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithTimeout configures the time limit of each request
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.http.Timeout = timeout
+	}
+}
+
+// WithBaseTransport configures the transport wrapped by the instrumentation
+func WithBaseTransport(base http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		c.http.Transport = NewTransport(base)
+	}
+}
+
+// WithTLSConfig configures the tls used to connect to the servers, nil keeps the default configuration
+func WithTLSConfig(cfg *tls.Config) ClientOption {
+	return func(c *Client) {
+		if cfg == nil {
+			return
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = cfg
+		c.http.Transport = NewTransport(transport)
+	}
+}
+
+// WithDefaultHeader configures a header sent on every request
+func WithDefaultHeader(key, value string) ClientOption {
+	return func(c *Client) {
+		c.header.Set(key, value)
+	}
+}
+
+type (
+	requestConfig struct {
+		header http.Header
+		query  url.Values
+	}
+
+	RequestOption func(*requestConfig)
+)
+
+// WithHeader sets a header on the request
+func WithHeader(key, value string) RequestOption {
+	return func(c *requestConfig) {
+		c.header.Set(key, value)
+	}
+}
+
+// WithAccept overrides the media types accepted in the response
+func WithAccept(mediaTypes ...string) RequestOption {
+	return func(c *requestConfig) {
+		c.header.Set("Accept", strings.Join(mediaTypes, ", "))
+	}
+}
+
+// WithQuery adds the query parameters to the request url
+func WithQuery(query *Query) RequestOption {
+	return func(c *requestConfig) {
+		for key, values := range query.values {
+			c.query[key] = append(c.query[key], values...)
+		}
+	}
+}
+
+// Query builds the query string of a request.
+type Query struct {
+	values url.Values
+}
 
-// Error fulfills the error interface.
-func (se *ResponseError) Error() string {
-	return fmt.Sprintf("response error for %s", se.Request.URL.Redacted())
+func NewQuery() *Query {
+	return &Query{values: url.Values{}}
+}
+
+// Set replaces the values of the key
+func (q *Query) Set(key string, value interface{}) *Query {
+	q.values.Set(key, fmt.Sprint(value))
+	return q
+}
+
+// Add appends the value to the key
+func (q *Query) Add(key string, value interface{}) *Query {
+	q.values.Add(key, fmt.Sprint(value))
+	return q
+}
+
+func (q *Query) Encode() string {
+	return q.values.Encode()
+}
+
+// Do sends the body encoded as json and decodes the json response.
+// Failed responses are returned as ResponseError, or as Error when the upstream answered with a problem.
+func Do[Req, Resp any](
+	ctx context.Context, c *Client, method, url string, body Req, opts ...RequestOption,
+) (resp Resp, err error) {
+	defer func() {
+		telemetry.RecordResult(ctx, err)
+	}()
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return resp, fmt.Errorf("failed to encode json body: %w", err)
+	}
+
+	err = c.call(ctx, method, url, bytes.NewReader(payload), &resp, opts)
+	return resp, err
+}
+
+// Get fetches the url and decodes the json response.
+func Get[Resp any](ctx context.Context, c *Client, url string, opts ...RequestOption) (resp Resp, err error) {
+	defer func() {
+		telemetry.RecordResult(ctx, err)
+	}()
+
+	err = c.call(ctx, http.MethodGet, url, nil, &resp, opts)
+	return resp, err
+}
+
+func Post[Req, Resp any](ctx context.Context, c *Client, url string, body Req, opts ...RequestOption) (Resp, error) {
+	return Do[Req, Resp](ctx, c, http.MethodPost, url, body, opts...)
+}
+
+func Put[Req, Resp any](ctx context.Context, c *Client, url string, body Req, opts ...RequestOption) (Resp, error) {
+	return Do[Req, Resp](ctx, c, http.MethodPut, url, body, opts...)
+}
+
+func Patch[Req, Resp any](ctx context.Context, c *Client, url string, body Req, opts ...RequestOption) (Resp, error) {
+	return Do[Req, Resp](ctx, c, http.MethodPatch, url, body, opts...)
+}
+
+func Delete[Req, Resp any](ctx context.Context, c *Client, url string, body Req, opts ...RequestOption) (Resp, error) {
+	return Do[Req, Resp](ctx, c, http.MethodDelete, url, body, opts...)
+}
+
+// maxStreamLineSize bounds the size of a ndjson line, the scanner keeps 64KiB at most by default
+const maxStreamLineSize = 1 << 20
+
+// Stream sends the json body and calls fn for every decoded line of the ndjson response, it stops on the first
+// error returned by fn. A nil body of type any sends no body, e.g. Stream[any, Item](ctx, c, "GET", url, nil, fn).
+func Stream[Req, Resp any](
+	ctx context.Context, c *Client, method, url string, body Req, fn func(Resp) error, opts ...RequestOption,
+) (err error) {
+	defer func() {
+		telemetry.RecordResult(ctx, err)
+	}()
+
+	var payload io.Reader
+	if any(body) != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode json body: %w", err)
+		}
+		payload = bytes.NewReader(data)
+	}
+
+	res, err := c.send(ctx, method, url, payload, append([]RequestOption{WithAccept(NDJSONContentType)}, opts...))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err := checkContentType(res, NDJSONContentType); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLineSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var item Resp
+		if err := json.Unmarshal(line, &item); err != nil {
+			return fmt.Errorf("failed to decode ndjson line: %w", err)
+		}
+
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
 }
 
 // GetJSON fetch the given url and try to decode the response as json
@@ -42,14 +292,24 @@ func GetJSON(ctx context.Context, url string, dst interface{}) (err error) {
 		telemetry.RecordResult(ctx, err)
 	}()
 
-	res, err := otelhttp.Get(ctx, url)
+	return DefaultClient.call(ctx, http.MethodGet, url, nil, dst, nil)
+}
+
+func (c *Client) call(
+	ctx context.Context, method, url string, body io.Reader, dst interface{}, opts []RequestOption,
+) error {
+	res, err := c.send(ctx, method, url, body, opts)
 	if err != nil {
-		return fmt.Errorf("failed to fetch '%s': %w", url, err)
+		return err
 	}
 	defer res.Body.Close()
 
-	if !collections.SliceContains(res.StatusCode, successfulStatuses) {
-		return fmt.Errorf("%w: unexpected status: %d", (*ResponseError)(res), res.StatusCode)
+	if res.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	if err := checkContentType(res, JSONContentType); err != nil {
+		return err
 	}
 
 	if err := libjson.Decode(res.Body, dst); err != nil {
@@ -58,3 +318,105 @@ func GetJSON(ctx context.Context, url string, dst interface{}) (err error) {
 
 	return nil
 }
+
+// send executes the request returning the response only when it was successful.
+func (c *Client) send(
+	ctx context.Context, method, rawURL string, body io.Reader, opts []RequestOption,
+) (*http.Response, error) {
+	cfg := requestConfig{
+		header: c.header.Clone(),
+		query:  url.Values{},
+	}
+	cfg.header.Set("Accept", JSONContentType+", "+ProblemContentType)
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.resolve(rawURL), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for '%s': %w", rawURL, err)
+	}
+
+	req.Header = cfg.header
+	if body != nil {
+		req.Header.Set("Content-Type", JSONContentType)
+	}
+
+	if len(cfg.query) > 0 {
+		query := req.URL.Query()
+		for key, values := range cfg.query {
+			query[key] = append(query[key], values...)
+		}
+		req.URL.RawQuery = query.Encode()
+	}
+
+	start := time.Now()
+	res, err := c.http.Do(req)
+	if err != nil {
+		return nil, recordResponseError(ctx, newResponseError(req, nil, time.Since(start), err))
+	}
+
+	if !collections.SliceContains(res.StatusCode, successfulStatuses) {
+		defer res.Body.Close()
+		return nil, decodeProblem(recordResponseError(ctx, newResponseError(req, res, time.Since(start), nil)))
+	}
+
+	return res, nil
+}
+
+func (c *Client) resolve(rawURL string) string {
+	if c.baseURL == "" || strings.Contains(rawURL, "://") {
+		return rawURL
+	}
+	return c.baseURL + "/" + strings.TrimPrefix(rawURL, "/")
+}
+
+// checkContentType verifies the response has the expected media type, a missing content type is accepted.
+func checkContentType(res *http.Response, expected string) error {
+	contentType := res.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("invalid response content type '%s': %w", contentType, err)
+	}
+
+	if mediaType != expected && !(expected == JSONContentType && strings.HasSuffix(mediaType, "+json")) {
+		return fmt.Errorf("%w: got '%s', want '%s'", ErrUnexpectedContentType, mediaType, expected)
+	}
+
+	return nil
+}
+
+func recordResponseError(ctx context.Context, err *ResponseError) *ResponseError {
+	trace.SpanFromContext(ctx).SetAttributes(err.Attributes()...)
+	return err
+}
+
+// decodeProblem converts a problem json body to an application error, any other body
+// is reported as the response error.
+func decodeProblem(respErr *ResponseError) error {
+	mediaType, _, _ := mime.ParseMediaType(respErr.Header.Get("Content-Type"))
+	if mediaType != ProblemContentType {
+		return respErr
+	}
+
+	var problem Problem
+	if err := json.Unmarshal(respErr.Body, &problem); err != nil {
+		return respErr
+	}
+
+	// a problem without a valid status or code takes the ones of the response, the error can be written
+	// back as is
+	if problem.Status < http.StatusBadRequest || problem.Status > 599 {
+		problem.Status = respErr.StatusCode
+	}
+	if problem.Code == "" {
+		problem.Code = CodeUpstream
+	}
+
+	return problem.Err(respErr)
+}
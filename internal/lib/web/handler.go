@@ -2,23 +2,105 @@ package web
 
 import (
 	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+var ready int32
+
+// SetReady changes the status reported by the readiness check
+func SetReady(value bool) {
+	var v int32
+	if value {
+		v = 1
+	}
+	atomic.StoreInt32(&ready, v)
+}
+
+// IsReady reports whether the service should receive traffic
+func IsReady() bool {
+	return atomic.LoadInt32(&ready) == 1
+}
 
-	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+// HealthCheck reports the details of a component, an error marks the service as degraded
+type HealthCheck func() (interface{}, error)
+
+var (
+	healthChecksMu sync.RWMutex
+	healthChecks   = map[string]HealthCheck{}
 )
 
-func Handler(mux *http.ServeMux, route string, handler http.Handler) {
-	mux.Handle(route, otelhttp.WithRouteTag(route, handler))
+// AddHealthCheck reports the component in the health endpoints, a check with the same name is replaced.
+func AddHealthCheck(name string, check HealthCheck) {
+	healthChecksMu.Lock()
+	defer healthChecksMu.Unlock()
+
+	healthChecks[name] = check
 }
 
-func HealthCheckHandler(mux *http.ServeMux, service, version string) {
-	mux.HandleFunc(
+// runHealthChecks returns the result of every check and whether one of them failed.
+func runHealthChecks() (Envelope, bool) {
+	healthChecksMu.RLock()
+	defer healthChecksMu.RUnlock()
+
+	names := make([]string, 0, len(healthChecks))
+	for name := range healthChecks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := Envelope{}
+	degraded := false
+	for _, name := range names {
+		detail, err := healthChecks[name]()
+
+		result := Envelope{"status": "ok", "detail": detail}
+		if err != nil {
+			result["status"], result["error"] = "degraded", err.Error()
+			degraded = true
+		}
+		results[name] = result
+	}
+
+	return results, degraded
+}
+
+// HealthCheckHandler registers the liveness check on /healthcheck and the readiness check on /healthcheck/ready.
+// Both report the registered health checks, a degraded service stays alive and ready.
+func HealthCheckHandler(router *Router, service, version string) {
+	router.Get(
 		"/healthcheck", func(w http.ResponseWriter, r *http.Request) {
+			checks, degraded := runHealthChecks()
+
+			status := "ok"
+			if degraded {
+				status = "degraded"
+			}
+
 			data := Envelope{
 				"service": service,
 				"version": version,
-				"status":  "ok",
+				"status":  status,
+				"checks":  checks,
 			}
 			WriteJSON(w, http.StatusOK, data)
 		},
 	)
+
+	router.Get(
+		"/healthcheck/ready", func(w http.ResponseWriter, r *http.Request) {
+			checks, degraded := runHealthChecks()
+
+			status, code := "ready", http.StatusOK
+			switch {
+			case !IsReady():
+				status, code = "not_ready", http.StatusServiceUnavailable
+			case degraded:
+				status = "degraded"
+			}
+
+			WriteJSON(w, code, Envelope{"service": service, "version": version, "status": status, "checks": checks})
+		},
+	)
 }
@@ -4,10 +4,9 @@ import (
 	"net/http"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
-	metricGlobal "go.opentelemetry.io/otel/metric/global"
-	"go.opentelemetry.io/otel/metric/instrument/syncint64"
-	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
 )
 
 const (
@@ -18,6 +17,7 @@ const (
 type responseWriterInterceptor struct {
 	http.ResponseWriter
 	statusCode int
+	written    int64
 }
 
 func (w *responseWriterInterceptor) WriteHeader(statusCode int) {
@@ -25,13 +25,18 @@ func (w *responseWriterInterceptor) WriteHeader(statusCode int) {
 	w.ResponseWriter.WriteHeader(statusCode)
 }
 
+func (w *responseWriterInterceptor) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
 func NewRequestCounterHandler(next http.Handler, filters FilterURLs) http.Handler {
-	reqCounter, err := metricGlobal.Meter(
+	reqCounter, err := otel.Meter(
 		instrumentationName,
 		metric.WithInstrumentationVersion(instrumentationVersion),
 	).
-		SyncInt64().
-		Counter("http.server.request_count")
+		Int64Counter("http.server.request_count")
 	if err != nil {
 		otel.Handle(err)
 	}
@@ -44,7 +49,7 @@ func NewRequestCounterHandler(next http.Handler, filters FilterURLs) http.Handle
 }
 
 type RequestCounterHandler struct {
-	reqCounter syncint64.Counter
+	reqCounter metric.Int64Counter
 	next       http.Handler
 	filters    FilterURLs
 }
@@ -61,15 +66,14 @@ func (h *RequestCounterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request
 	}
 	h.next.ServeHTTP(wi, r)
 
-	h.reqCounter.Add(
-		r.Context(),
-		1,
-		append(
-			semconv.HTTPClientAttributesFromHTTPRequest(r),
-			append(
-				semconv.NetAttributesFromHTTPRequest("tcp", r),
-				semconv.HTTPStatusCodeKey.Int(wi.statusCode),
-			)...,
-		)...,
+	// the client address, port and user agent are left out, they make a new series for almost every request
+	attrs := append(
+		[]attribute.KeyValue{
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.HTTPResponseStatusCode(wi.statusCode),
+			semconv.HTTPRoute(RouteFromContext(r.Context())),
+		},
+		BaggageAttributesFromContext(r.Context())...,
 	)
+	h.reqCounter.Add(r.Context(), 1, metric.WithAttributes(attrs...))
 }
@@ -0,0 +1,134 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const ProblemContentType = "application/problem+json"
+
+const problemTypePrefix = "urn:otel-playground:problem:"
+
+// Error codes shared by the services.
+const (
+	CodeInternal         = "internal_error"
+	CodeBadRequest       = "bad_request"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeUpstream         = "upstream_error"
+	CodeUnavailable      = "unavailable"
+)
+
+// Error is an application error, only the code, status and message are sent to the clients,
+// the cause is kept for the traces and logs.
+type Error struct {
+	Code      string
+	Status    int
+	Message   string
+	Cause     error
+	Retryable bool
+}
+
+// NewError returns an application error that should not be retried.
+func NewError(status int, code, message string, cause error) *Error {
+	return &Error{
+		Code:    code,
+		Status:  status,
+		Message: message,
+		Cause:   cause,
+	}
+}
+
+// NewRetryableError returns an application error for failures that may succeed when retried.
+func NewRetryableError(status int, code, message string, cause error) *Error {
+	err := NewError(status, code, message, cause)
+	err.Retryable = true
+	return err
+}
+
+// Error fulfills the error interface.
+func (e *Error) Error() string {
+	if e.Cause == nil {
+		return fmt.Sprintf("%s: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Cause)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// NewUpstreamError returns an application error for failed calls to other services,
+// it can be retried when the upstream failure can.
+func NewUpstreamError(message string, cause error) *Error {
+	err := NewError(http.StatusBadGateway, CodeUpstream, message, cause)
+	err.Retryable = IsRetryable(cause)
+	return err
+}
+
+// IsRetryable reports whether any application error in err's chain can be retried.
+func IsRetryable(err error) bool {
+	var appErr *Error
+	return errors.As(err, &appErr) && appErr.Retryable
+}
+
+// Problem is the RFC 7807 representation of an application error.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	Retryable bool   `json:"retryable"`
+}
+
+func newProblem(ctx context.Context, err *Error) Problem {
+	problem := Problem{
+		Type:      problemTypePrefix + err.Code,
+		Title:     http.StatusText(err.Status),
+		Status:    err.Status,
+		Detail:    err.Message,
+		Code:      err.Code,
+		Retryable: err.Retryable,
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		problem.Instance = sc.TraceID().String()
+	}
+
+	return problem
+}
+
+// Err converts the problem back to an application error.
+func (p Problem) Err(cause error) *Error {
+	return &Error{
+		Code:      p.Code,
+		Status:    p.Status,
+		Message:   p.Detail,
+		Cause:     cause,
+		Retryable: p.Retryable,
+	}
+}
+
+// asError returns the application error in err's chain, unknown errors become internal errors.
+func asError(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return internalError(err)
+}
+
+func internalError(err error) *Error {
+	return NewError(
+		http.StatusInternalServerError,
+		CodeInternal,
+		"the server encountered a problem and could not process your request",
+		err,
+	)
+}
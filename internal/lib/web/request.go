@@ -0,0 +1,97 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	RequestIDHeader     = "X-Request-ID"
+	TraceIDHeader       = "X-Trace-Id"
+	TraceResponseHeader = "traceresponse"
+)
+
+type contextKey string
+
+const requestInfoKey contextKey = "request_info"
+
+// requestInfo holds the per-request data shared between the middlewares and the route handlers.
+type requestInfo struct {
+	id      string
+	route   string
+	baggage []attribute.KeyValue
+}
+
+func requestInfoFromContext(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey).(*requestInfo)
+	return info
+}
+
+// RequestIDFromContext returns the request id assigned to the incoming request, if any
+func RequestIDFromContext(ctx context.Context) string {
+	if info := requestInfoFromContext(ctx); info != nil {
+		return info.id
+	}
+	return ""
+}
+
+// RouteFromContext returns the route pattern matched by the incoming request, if any
+func RouteFromContext(ctx context.Context) string {
+	if info := requestInfoFromContext(ctx); info != nil {
+		return info.route
+	}
+	return ""
+}
+
+func setRoute(ctx context.Context, route string) {
+	if info := requestInfoFromContext(ctx); info != nil {
+		info.route = route
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// validRequestID matches the request ids reused from the callers, they end up in the logs, the spans
+// and the response headers
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// NewRequestIDHandler assigns a request id to every request, reusing the one sent by the caller when it is valid,
+// and returns it together with the trace id in the response headers.
+func NewRequestIDHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID.MatchString(id) {
+				id = newRequestID()
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+
+			span := trace.SpanFromContext(r.Context())
+			if sc := span.SpanContext(); sc.IsValid() {
+				span.SetAttributes(attribute.String("http.request_id", id))
+				w.Header().Set(TraceIDHeader, sc.TraceID().String())
+				w.Header().Set(
+					TraceResponseHeader,
+					fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags()),
+				)
+			}
+
+			ctx := context.WithValue(r.Context(), requestInfoKey, &requestInfo{id: id})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		},
+	)
+}
@@ -2,31 +2,57 @@ package web
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+
+	"github.com/username/otel-playground/internal/lib/telemetry"
 )
 
 func WriteJSON(w http.ResponseWriter, status int, data interface{}) {
+	writeJSON(w, status, "application/json", data)
+}
+
+func writeJSON(w http.ResponseWriter, status int, contentType string, data interface{}) {
 	resp, err := json.Marshal(data)
 	if err != nil {
-		ServerErrorResponse(w, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return
 	}
 
 	// Append a newline to the JSON. This is just a small nicety to make it easier to view in terminal applications.
 	resp = append(resp, '\n')
 
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", contentType)
 	w.WriteHeader(status)
 	_, _ = w.Write(resp)
 }
 
-func ErrorResponse(w http.ResponseWriter, status int, message interface{}, err error) {
-	if WriteJSON(w, status, Envelope{"error": message, "cause": err.Error()}); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+// ErrorResponse writes the error as problem json, errors which aren't application errors are
+// reported as internal errors. The cause of server errors is only recorded in the trace.
+func ErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	appErr := asError(err)
+	if appErr.Status >= http.StatusInternalServerError {
+		telemetry.RecordError(r.Context(), appErr)
 	}
+	writeJSON(w, appErr.Status, ProblemContentType, newProblem(r.Context(), appErr))
+}
+
+func ServerErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
+	ErrorResponse(w, r, internalError(err))
+}
+
+// BadRequestResponse writes a bad request problem, the error message is sent to the client.
+func BadRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
+	ErrorResponse(w, r, NewError(http.StatusBadRequest, CodeBadRequest, err.Error(), nil))
+}
+
+func NotFoundResponse(w http.ResponseWriter, r *http.Request) {
+	ErrorResponse(
+		w, r, NewError(http.StatusNotFound, CodeNotFound, "the requested resource could not be found", nil),
+	)
 }
 
-func ServerErrorResponse(w http.ResponseWriter, err error) {
-	message := "the server encountered a problem and could not process your request"
-	ErrorResponse(w, http.StatusInternalServerError, message, err)
+func MethodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
+	message := fmt.Sprintf("the %s method is not supported for this resource", r.Method)
+	ErrorResponse(w, r, NewError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, message, nil))
 }
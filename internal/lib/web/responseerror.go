@@ -0,0 +1,138 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+)
+
+// maxErrorBodySize bounds how much of an unexpected response body is kept in the error.
+const maxErrorBodySize = 2048
+
+const attemptKey contextKey = "attempt"
+
+// ContextWithAttempt records the attempt number of the calls made with the returned context.
+func ContextWithAttempt(ctx context.Context, attempt int) context.Context {
+	return context.WithValue(ctx, attemptKey, attempt)
+}
+
+func attemptFromContext(ctx context.Context) int {
+	if attempt, ok := ctx.Value(attemptKey).(int); ok {
+		return attempt
+	}
+	return 1
+}
+
+// ResponseError describes a failed call to another service, either the request could not be
+// completed (Err is set) or the response had an unexpected status.
+type ResponseError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Latency    time.Duration
+	Attempt    int
+	Err        error
+}
+
+func newResponseError(req *http.Request, res *http.Response, latency time.Duration, err error) *ResponseError {
+	respErr := &ResponseError{
+		Method:  req.Method,
+		URL:     req.URL.Redacted(),
+		Latency: latency,
+		Attempt: attemptFromContext(req.Context()),
+		Err:     err,
+	}
+
+	if res != nil {
+		respErr.StatusCode = res.StatusCode
+		respErr.Header = res.Header
+		respErr.Body, _ = io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize))
+	}
+
+	return respErr
+}
+
+// Error fulfills the error interface.
+func (e *ResponseError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s %s failed after %s (attempt %d): %v", e.Method, e.URL, e.Latency, e.Attempt, e.Err)
+	}
+	return fmt.Sprintf(
+		"%s %s unexpected status %d after %s (attempt %d): %s",
+		e.Method, e.URL, e.StatusCode, e.Latency, e.Attempt, e.Body,
+	)
+}
+
+func (e *ResponseError) Unwrap() error {
+	return e.Err
+}
+
+// Attributes returns the error details to be set on a span.
+func (e *ResponseError) Attributes() []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.HTTPRequestMethodKey.String(e.Method),
+		semconv.URLFull(e.URL),
+		attribute.Int64("http.response.latency_ms", e.Latency.Milliseconds()),
+		attribute.Int("http.attempt", e.Attempt),
+	}
+
+	if e.StatusCode != 0 {
+		attrs = append(
+			attrs,
+			semconv.HTTPResponseStatusCode(e.StatusCode),
+			attribute.String("http.response.body", string(e.Body)),
+		)
+	}
+
+	for _, key := range []string{"Content-Type", RequestIDHeader, TraceIDHeader} {
+		if value := e.Header.Get(key); value != "" {
+			attrs = append(attrs, attribute.String("http.response.header."+headerAttributeName(key), value))
+		}
+	}
+
+	return attrs
+}
+
+func headerAttributeName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "-", "_"))
+}
+
+func asResponseError(err error) (*ResponseError, bool) {
+	var respErr *ResponseError
+	ok := errors.As(err, &respErr)
+	return respErr, ok
+}
+
+// IsNotFound reports whether the upstream answered not found.
+func IsNotFound(err error) bool {
+	respErr, ok := asResponseError(err)
+	return ok && respErr.StatusCode == http.StatusNotFound
+}
+
+// IsServerError reports whether the upstream answered with a 5xx status.
+func IsServerError(err error) bool {
+	respErr, ok := asResponseError(err)
+	return ok && respErr.StatusCode >= http.StatusInternalServerError
+}
+
+// IsTimeout reports whether the call timed out, either on our side or on the upstream.
+// The caller's own deadline is a timeout too, check the context before retrying.
+func IsTimeout(err error) bool {
+	respErr, ok := asResponseError(err)
+	if ok && (respErr.StatusCode == http.StatusRequestTimeout || respErr.StatusCode == http.StatusGatewayTimeout) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
@@ -0,0 +1,111 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware wraps a handler with extra behaviour.
+type Middleware func(http.Handler) http.Handler
+
+// Router dispatches requests by method and path pattern, patterns can have parameters like `/passwords/{id}`.
+// Every matched request has its route pattern set as tag on the server span and metrics.
+type Router struct {
+	mux chi.Router
+}
+
+// NewRouter returns a router answering json responses for unknown routes and methods.
+func NewRouter() *Router {
+	mux := chi.NewRouter()
+	mux.NotFound(NotFoundResponse)
+	mux.MethodNotAllowed(MethodNotAllowedResponse)
+
+	return &Router{mux: mux}
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
+
+// Use appends middlewares to the router stack, it must be called before registering the routes.
+func (rt *Router) Use(middlewares ...Middleware) {
+	for _, mw := range middlewares {
+		rt.mux.Use(mw)
+	}
+}
+
+// Group creates a new inline router sharing the same prefix, the middlewares added to it
+// don't affect the parent router.
+func (rt *Router) Group(fn func(r *Router)) {
+	rt.mux.Group(
+		func(r chi.Router) {
+			fn(&Router{mux: r})
+		},
+	)
+}
+
+// Route creates a sub router mounted on the given prefix.
+func (rt *Router) Route(prefix string, fn func(r *Router)) {
+	rt.mux.Route(
+		prefix, func(r chi.Router) {
+			fn(&Router{mux: r})
+		},
+	)
+}
+
+// Handle registers the handler for the given method and pattern.
+func (rt *Router) Handle(method, pattern string, handler http.Handler) {
+	rt.mux.Method(method, pattern, routeTagHandler(handler))
+}
+
+func (rt *Router) Get(pattern string, handler http.HandlerFunc) {
+	rt.Handle(http.MethodGet, pattern, handler)
+}
+
+func (rt *Router) Post(pattern string, handler http.HandlerFunc) {
+	rt.Handle(http.MethodPost, pattern, handler)
+}
+
+func (rt *Router) Put(pattern string, handler http.HandlerFunc) {
+	rt.Handle(http.MethodPut, pattern, handler)
+}
+
+func (rt *Router) Patch(pattern string, handler http.HandlerFunc) {
+	rt.Handle(http.MethodPatch, pattern, handler)
+}
+
+func (rt *Router) Delete(pattern string, handler http.HandlerFunc) {
+	rt.Handle(http.MethodDelete, pattern, handler)
+}
+
+// PathParam returns the value of the named parameter from the matched route pattern.
+func PathParam(r *http.Request, name string) string {
+	return chi.URLParam(r, name)
+}
+
+// routeTagHandler records the full matched pattern, which is only known once the request reaches the final handler.
+func routeTagHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				route := rctx.RoutePattern()
+				attr := semconv.HTTPRouteKey.String(route)
+
+				span := trace.SpanFromContext(r.Context())
+				span.SetName(r.Method + " " + route)
+				span.SetAttributes(attr)
+
+				labeler, _ := otelhttp.LabelerFromContext(r.Context())
+				labeler.Add(attr)
+
+				setRoute(r.Context(), route)
+			}
+
+			handler.ServeHTTP(w, r)
+		},
+	)
+}
@@ -2,6 +2,7 @@ package web
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log"
@@ -9,53 +10,255 @@ import (
 	"os"
 	"os/signal"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+
+	"github.com/username/otel-playground/internal/lib/redact"
+	"github.com/username/otel-playground/internal/lib/telemetry"
 )
 
-func Server(port int, handler http.Handler, serverName string, filters FilterURLs) error {
-	var wg sync.WaitGroup
+type (
+	serverConfig struct {
+		port              int
+		adminPort         int
+		name              string
+		filters           FilterURLs
+		tlsConfig         *tls.Config
+		readTimeout       time.Duration
+		readHeaderTimeout time.Duration
+		writeTimeout      time.Duration
+		idleTimeout       time.Duration
+		maxHeaderBytes    int
+		drainDelay        time.Duration
+		shutdownTimeout   time.Duration
+		flushTimeout      time.Duration
+		telemetry         *telemetry.Client
+		baggagePolicy     BaggagePolicy
+		accessLogOptions  []AccessLogOption
+	}
+
+	ServerOption func(*serverConfig)
+)
+
+// WithPort configures the port to listen on
+func WithPort(port int) ServerOption {
+	return func(c *serverConfig) {
+		c.port = port
+	}
+}
 
-	srv := &http.Server{
-		Addr: fmt.Sprintf(":%d", port),
-		Handler: otelhttp.NewHandler(
-			NewRequestCounterHandler(handler, filters),
-			serverName,
-			otelhttp.WithFilter(filters.Use),
-		),
-		IdleTimeout:  time.Minute,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 30 * time.Second,
+// WithAdminPort serves pprof, expvar and the runtime introspection endpoints on a separate port, 0 disables it
+func WithAdminPort(port int) ServerOption {
+	return func(c *serverConfig) {
+		c.adminPort = port
 	}
+}
 
-	shutdownError := make(chan error)
+// WithName configures the server name used as operation of the server spans
+func WithName(name string) ServerOption {
+	return func(c *serverConfig) {
+		c.name = name
+	}
+}
 
-	go func() {
-		quit := make(chan os.Signal, 1)
-		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-		<-quit
+// WithFilters configures the urls that are neither traced, counted nor logged
+func WithFilters(filters FilterURLs) ServerOption {
+	return func(c *serverConfig) {
+		c.filters = filters
+	}
+}
+
+// WithTLS serves https with the given configuration, nil keeps serving plain http
+func WithTLS(cfg *tls.Config) ServerOption {
+	return func(c *serverConfig) {
+		c.tlsConfig = cfg
+	}
+}
+
+// WithReadTimeout configures the maximum duration for reading the entire request
+func WithReadTimeout(timeout time.Duration) ServerOption {
+	return func(c *serverConfig) {
+		c.readTimeout = timeout
+	}
+}
+
+// WithReadHeaderTimeout configures the maximum duration for reading the request headers
+func WithReadHeaderTimeout(timeout time.Duration) ServerOption {
+	return func(c *serverConfig) {
+		c.readHeaderTimeout = timeout
+	}
+}
+
+// WithWriteTimeout configures the maximum duration before timing out the response writes
+func WithWriteTimeout(timeout time.Duration) ServerOption {
+	return func(c *serverConfig) {
+		c.writeTimeout = timeout
+	}
+}
+
+// WithIdleTimeout configures the maximum duration to wait for the next request on keep-alive connections
+func WithIdleTimeout(timeout time.Duration) ServerOption {
+	return func(c *serverConfig) {
+		c.idleTimeout = timeout
+	}
+}
+
+// WithMaxHeaderBytes configures the maximum size of the request headers
+func WithMaxHeaderBytes(size int) ServerOption {
+	return func(c *serverConfig) {
+		c.maxHeaderBytes = size
+	}
+}
 
-		log.Println("shutting down server")
+// WithDrainDelay configures how long the server keeps serving after reporting not ready,
+// giving the load balancers time to stop sending new requests
+func WithDrainDelay(delay time.Duration) ServerOption {
+	return func(c *serverConfig) {
+		c.drainDelay = delay
+	}
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
+// WithShutdownTimeout configures how long the shutdown waits for the in-flight requests
+// and the background tasks
+func WithShutdownTimeout(timeout time.Duration) ServerOption {
+	return func(c *serverConfig) {
+		c.shutdownTimeout = timeout
+	}
+}
+
+// WithFlushTimeout configures how long the shutdown waits for the telemetry flush, it starts after
+// the requests and the background tasks so a slow shutdown doesn't lose their telemetry
+func WithFlushTimeout(timeout time.Duration) ServerOption {
+	return func(c *serverConfig) {
+		c.flushTimeout = timeout
+	}
+}
 
-		if err := srv.Shutdown(ctx); err != nil {
-			shutdownError <- err
+// WithTelemetry reports the telemetry status in the health endpoints and flushes and stops
+// the telemetry client as the last shutdown step
+func WithTelemetry(client telemetry.Client) ServerOption {
+	return func(c *serverConfig) {
+		c.telemetry = &client
+	}
+}
+
+// WithBaggagePolicy configures the baggage accepted from the callers, DefaultBaggagePolicy by default
+func WithBaggagePolicy(policy BaggagePolicy) ServerOption {
+	return func(c *serverConfig) {
+		c.baggagePolicy = policy
+	}
+}
+
+// WithResponseLogging adds the json response bodies to the access log when enabled,
+// redacted by the redactor rules unless it is nil
+func WithResponseLogging(enabled bool, redactor *redact.Redactor) ServerOption {
+	return func(c *serverConfig) {
+		if enabled {
+			c.accessLogOptions = append(c.accessLogOptions, WithResponseBodies(redactor))
 		}
+	}
+}
+
+type Server struct {
+	config serverConfig
+	srv    *http.Server
+	admin  *http.Server
+}
+
+func NewServer(handler http.Handler, opts ...ServerOption) *Server {
+	cfg := serverConfig{
+		port:              5000,
+		name:              "server",
+		readTimeout:       10 * time.Second,
+		readHeaderTimeout: 5 * time.Second,
+		writeTimeout:      30 * time.Second,
+		idleTimeout:       time.Minute,
+		maxHeaderBytes:    http.DefaultMaxHeaderBytes,
+		shutdownTimeout:   5 * time.Second,
+		flushTimeout:      5 * time.Second,
+		baggagePolicy:     DefaultBaggagePolicy,
+	}
+
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	s := &Server{
+		config: cfg,
+		srv: &http.Server{
+			Addr: fmt.Sprintf(":%d", cfg.port),
+			Handler: otelhttp.NewHandler(
+				NewRequestIDHandler(
+					NewBaggageHandler(
+						NewAccessLogHandler(
+							NewRequestCounterHandler(handler, cfg.filters),
+							cfg.filters,
+							cfg.accessLogOptions...,
+						),
+						cfg.baggagePolicy,
+					),
+				),
+				cfg.name,
+				otelhttp.WithFilter(cfg.filters.Use),
+				otelhttp.WithPropagators(otel.GetTextMapPropagator()),
+			),
+			TLSConfig:         cfg.tlsConfig,
+			ReadTimeout:       cfg.readTimeout,
+			ReadHeaderTimeout: cfg.readHeaderTimeout,
+			WriteTimeout:      cfg.writeTimeout,
+			IdleTimeout:       cfg.idleTimeout,
+			MaxHeaderBytes:    cfg.maxHeaderBytes,
+		},
+	}
+
+	if cfg.adminPort != 0 {
+		s.admin = newAdminServer(cfg)
+	}
+
+	if cfg.telemetry != nil {
+		addTelemetryHealthCheck(*cfg.telemetry)
+	}
 
-		log.Println("completing background tasks")
+	return s
+}
 
-		wg.Wait()
+// Run serves the requests until the process receives SIGINT or SIGTERM, then it reports not ready,
+// waits the drain delay, stops accepting requests, waits the background tasks and flushes the telemetry.
+func (s *Server) Run() error {
+	shutdownError := make(chan error, 1)
 
-		shutdownError <- nil
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		<-quit
+
+		shutdownError <- s.shutdown()
 	}()
 
-	log.Printf("port: %d", port)
+	if s.admin != nil {
+		go func() {
+			log.Printf("admin port: %d", s.config.adminPort)
+			if err := s.admin.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("admin server failed: %v\n", err)
+			}
+		}()
+	}
 
-	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	log.Printf("port: %d, tls: %t", s.config.port, s.config.tlsConfig != nil)
+	SetReady(true)
+
+	var err error
+	if s.config.tlsConfig != nil {
+		// the certificates come from GetConfigForClient, which replaces the files since Go 1.22
+		err = s.srv.ListenAndServeTLS("", "")
+	} else {
+		err = s.srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 
@@ -67,3 +270,108 @@ func Server(port int, handler http.Handler, serverName string, filters FilterURL
 
 	return nil
 }
+
+func (s *Server) shutdown() error {
+	SetReady(false)
+
+	if s.config.drainDelay > 0 {
+		log.Printf("draining server for %s", s.config.drainDelay)
+		time.Sleep(s.config.drainDelay)
+	}
+
+	log.Println("shutting down server")
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.shutdownTimeout)
+	defer cancel()
+
+	err := s.srv.Shutdown(ctx)
+
+	log.Println("completing background tasks")
+
+	if waitErr := waitBackground(ctx); waitErr != nil && err == nil {
+		err = waitErr
+	}
+
+	// the admin server is the last to go so the shutdown itself can be profiled
+	if s.admin != nil {
+		if adminErr := s.admin.Shutdown(ctx); adminErr != nil && err == nil {
+			err = adminErr
+		}
+	}
+
+	if s.config.telemetry != nil {
+		log.Println("flushing telemetry")
+
+		flushCtx, cancelFlush := context.WithTimeout(context.Background(), s.config.flushTimeout)
+		defer cancelFlush()
+
+		s.config.telemetry.Shutdown(flushCtx)
+	}
+
+	return err
+}
+
+// exportFailureWindow is how long a failed export keeps the telemetry degraded
+const exportFailureWindow = time.Minute
+
+// addTelemetryHealthCheck reports the signals that failed to start and the recent export failures.
+func addTelemetryHealthCheck(client telemetry.Client) {
+	var lastExportFailure atomic.Value
+
+	client.OnError(
+		func(event telemetry.ErrorEvent) {
+			if event.Kind == telemetry.ErrorKindExport {
+				lastExportFailure.Store(event)
+			}
+		},
+	)
+
+	status := client.Status()
+	AddHealthCheck(
+		"telemetry", func() (interface{}, error) {
+			if err := status.Err(); err != nil {
+				return status, err
+			}
+
+			if event, ok := lastExportFailure.Load().(telemetry.ErrorEvent); ok &&
+				time.Since(event.Time) < exportFailureWindow {
+				return status, fmt.Errorf("export failed %s ago: %w", time.Since(event.Time).Round(time.Second), event.Err)
+			}
+
+			return status, nil
+		},
+	)
+}
+
+var backgroundTasks sync.WaitGroup
+
+// Background runs fn in a goroutine the server waits for before shutting down, panics are recovered and logged.
+func Background(fn func()) {
+	backgroundTasks.Add(1)
+
+	go func() {
+		defer backgroundTasks.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				log.Printf("background task panic: %v\n", err)
+			}
+		}()
+
+		fn()
+	}()
+}
+
+func waitBackground(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		backgroundTasks.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("background tasks did not complete: %w", ctx.Err())
+	}
+}